@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// accessManifestPath is where the roles pages require are written, for an
+// auth proxy or edge function in front of the site to enforce.
+var accessManifestPath = filepath.Join("public", ".access.json")
+
+// setRoles reads the roles a page requires from its roles front matter, or
+// access when it doesn't have roles, like roles: [staff, admin].
+func (p *page) setRoles() {
+	roles, ok := p.Meta["roles"]
+	if !ok {
+		roles = p.Meta["access"]
+	}
+
+	p.Roles = nil
+	for _, role := range parseList(roles) {
+		if role != "" {
+			p.Roles = append(p.Roles, role)
+		}
+	}
+}
+
+// writeAccessManifest maps the url of every file written for a page with
+// roles, its html, other outputs, amp variant and api json, to the roles it
+// requires. gen doesn't enforce them, and pages without roles are left out,
+// along with the manifest when no page has any.
+func writeAccessManifest(pages map[string]*page) {
+	manifest := make(map[string][]string)
+	for _, page := range pages {
+		if page.Type == "" || len(page.Roles) == 0 {
+			continue
+		}
+
+		for _, outPath := range page.accessPaths() {
+			manifest[pageURL(outPath)] = page.Roles
+		}
+		if page.hasHTMLOutput() {
+			manifest[pageLink(page.OutPath)] = page.Roles
+		}
+	}
+
+	if len(manifest) == 0 {
+		return
+	}
+
+	writeJSON(accessManifestPath, manifest)
+}
+
+// accessPaths returns the output paths of the files written for the page.
+func (p *page) accessPaths() []string {
+	paths := make([]string, 0)
+	if p.hasHTMLOutput() {
+		paths = append(paths, p.OutPath)
+	}
+
+	for _, format := range p.Outputs {
+		if format != "html" {
+			paths = append(paths, strings.TrimSuffix(p.OutPath, filepath.Ext(p.OutPath))+"."+format)
+		}
+	}
+
+	if p.AMPURL != "" {
+		paths = append(paths, ampOutPath(p.OutPath))
+	}
+
+	if cfg.API && p.hasHTMLOutput() {
+		paths = append(paths, apiOutPath(p.OutPath))
+	}
+
+	return paths
+}
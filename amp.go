@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+
+	"golang.org/x/net/html"
+)
+
+// ampTemplate renders the AMP variants, loaded from AMPTemplate when it
+// exists.
+var ampTemplate *template.Template
+
+// ampPages reports whether any page has an AMP variant, so the amphtml
+// links are only added when there's something to link to.
+var ampPages atomic.Bool
+
+func init() {
+	registerTransform("amp", ampPages.Load, addAMPLink)
+}
+
+// setAMP gives the page an AMP variant when its amp front matter is true,
+// or when it's unset and the page matches the AMP url globs.
+func (p *page) setAMP() {
+	enabled := matchesAny(cfg.AMP, pageURL(p.OutPath))
+	if amp, ok := p.Meta["amp"]; ok {
+		enabled = parseBool(amp)
+	}
+
+	if !enabled || !p.hasHTMLOutput() {
+		return
+	}
+
+	switch {
+	case p.Type != "MD":
+		log.Printf("[gen/parse/amp] amp is only supported on markdown pages, ignoring for %s", p.Path)
+		return
+	case p.Encrypted:
+		log.Printf("[gen/parse/amp] amp can't decrypt pages, ignoring for %s", p.Path)
+		return
+	}
+
+	p.AMPURL = pageLink(ampOutPath(p.OutPath))
+	ampPages.Store(true)
+}
+
+// ampOutPath returns where the AMP variant of a page is written, amp/index.html
+// in the directory the page's url names.
+func ampOutPath(outPath string) string {
+	dir := strings.TrimSuffix(outPath, filepath.Ext(outPath))
+	if filepath.Base(dir) == "index" {
+		dir = filepath.Dir(dir)
+	}
+
+	return filepath.Join(dir, "amp", "index.html")
+}
+
+// addAMPLink points pages with an AMP variant at it.
+func addAMPLink(doc *html.Node, p *page) error {
+	if p.AMPURL == "" {
+		return nil
+	}
+
+	url := strings.TrimSuffix(cfg.BaseURL, "/") + p.AMPURL
+	return appendToHead(doc, fmt.Sprintf(`<link rel="amphtml" href="%s">`, template.HTMLEscapeString(url)))
+}
+
+// renderAMP writes the AMP variant of a page with the AMP template and a
+// canonical link back to the page. None of the transforms run, they add
+// markup and scripts AMP doesn't allow.
+func renderAMP(p page) {
+	outPath := ampOutPath(p.OutPath)
+	if ampTemplate == nil {
+		report.Add("render/amp", p.Path, "unable to render the amp variant, %s doesn't exist", configuredTemplate(cfg.AMPTemplate, "amp.html"))
+		return
+	}
+
+	var rendered bytes.Buffer
+	err := ampTemplate.Execute(&rendered, p)
+	if err != nil {
+		log.Printf("[gen/render/amp] unable to render to file %s: %s", outPath, err)
+		return
+	}
+
+	doc, err := html.Parse(&rendered)
+	if err != nil {
+		log.Printf("[gen/render/amp] unable to parse rendered html for %s: %s", outPath, err)
+		return
+	}
+
+	canonical := strings.TrimSuffix(cfg.BaseURL, "/") + pageLink(p.OutPath)
+	err = appendToHead(doc, fmt.Sprintf(`<link rel="canonical" href="%s">`, template.HTMLEscapeString(canonical)))
+	if err != nil {
+		log.Printf("[gen/render/amp] unable to add canonical link to %s: %s", outPath, err)
+		return
+	}
+
+	err = output.MkdirAll(filepath.Dir(outPath))
+	if err != nil {
+		log.Printf("[gen/render/amp] unable to create directory for %s: %s", outPath, err)
+		return
+	}
+
+	f, err := output.Create(outPath)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", outPath, err)
+		return
+	}
+	defer f.Close()
+
+	err = html.Render(f, doc)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to render to file %s: %s", outPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", outPath)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var reElementID = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9]*)\b[^>]*?\sid="([^"]*)"`)
+
+func isHeadingTag(tag string) bool {
+	tag = strings.ToLower(tag)
+	return len(tag) == 2 && tag[0] == 'h' && tag[1] >= '1' && tag[1] <= '6'
+}
+
+// dedupeIDs scans rendered content for repeated id attributes, reporting
+// each conflict. Repeated heading ids are generated by the markdown renderer
+// so are renamed deterministically to id-1, id-2 and so on in document order,
+// any other duplicates are left as authored.
+func dedupeIDs(path string, content template.HTML) template.HTML {
+	s := string(content)
+	matches := reElementID.FindAllStringSubmatchIndex(s, -1)
+
+	taken := make(map[string]bool)
+	for _, match := range matches {
+		taken[s[match[4]:match[5]]] = true
+	}
+
+	seen := make(map[string]bool)
+	var result strings.Builder
+	last := 0
+	for _, match := range matches {
+		tag, id := s[match[2]:match[3]], s[match[4]:match[5]]
+		if !seen[id] {
+			seen[id] = true
+			continue
+		}
+
+		if !isHeadingTag(tag) {
+			report.Add("validate/ids", path, "duplicate id %s on <%s>", id, tag)
+			continue
+		}
+
+		n := 1
+		for taken[fmt.Sprintf("%s-%d", id, n)] {
+			n++
+		}
+		deduped := fmt.Sprintf("%s-%d", id, n)
+		taken[deduped] = true
+
+		report.Add("validate/ids", path, "duplicate heading id %s renamed to %s", id, deduped)
+
+		result.WriteString(s[last:match[4]])
+		result.WriteString(deduped)
+		last = match[5]
+	}
+	result.WriteString(s[last:])
+
+	return template.HTML(result.String())
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// apiPage is the data of a page written to public/api for clients that
+// fetch pages rather than load them.
+type apiPage struct {
+	Title string            `json:"title"`
+	URL   string            `json:"url"`
+	Date  string            `json:"date,omitempty"`
+	HTML  string            `json:"html"`
+	Meta  map[string]string `json:"meta"`
+}
+
+// generateAPI writes the metadata and rendered content of every published
+// html page to public/api, mirroring the output tree with .json files.
+// Markdown pages carry their content without the layout, html sources the
+// page they rendered to.
+func generateAPI(pages map[string]*page) {
+	for _, page := range pages {
+		if page.Type == "" || page.Draft || !page.hasHTMLOutput() {
+			continue
+		}
+
+		content := string(page.Content)
+		if page.Type == "HTML" {
+			rendered, err := output.ReadFile(page.OutPath)
+			if err != nil {
+				report.Add("render/api", page.Path, "unable to read the rendered page: %s", err)
+				continue
+			}
+			content = string(rendered)
+		}
+
+		writeJSON(apiOutPath(page.OutPath), newAPIPage(page, content))
+	}
+}
+
+func newAPIPage(p *page, content string) apiPage {
+	data := apiPage{
+		Title: p.Title,
+		URL:   pageLink(p.OutPath),
+		HTML:  content,
+		Meta:  p.Meta,
+	}
+	if !p.Date.IsZero() {
+		data.Date = p.Date.Format(time.RFC3339)
+	}
+
+	return data
+}
+
+// apiOutPath maps a page's output path to its file under public/api.
+func apiOutPath(outPath string) string {
+	rel := strings.TrimPrefix(filepath.ToSlash(outPath), "public/")
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel)) + ".json"
+
+	path := filepath.Join("public", "api", filepath.FromSlash(rel))
+	if err := output.MkdirAll(filepath.Dir(path)); err != nil {
+		report.Add("render/api", outPath, "unable to create directory for %s: %s", path, err)
+	}
+
+	return path
+}
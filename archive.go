@@ -0,0 +1,92 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type archiveEntry struct {
+	URL   string
+	Title string
+	Date  time.Time
+}
+
+type archiveMonth struct {
+	Month time.Month
+	Count int
+	Pages []archiveEntry
+}
+
+type archiveYear struct {
+	Year   int
+	Count  int
+	Months []archiveMonth
+}
+
+// generateArchive buckets every dated page by year and month, newest first.
+func generateArchive(pages map[string]*page) []archiveYear {
+	entries := make([]archiveEntry, 0)
+	for _, page := range pages {
+		if page.Type == "" || page.Date.IsZero() || !page.hasHTMLOutput() {
+			continue
+		}
+
+		entries = append(entries, archiveEntry{
+			URL:   pageLink(page.OutPath),
+			Title: page.Title,
+			Date:  page.Date,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Date.Equal(entries[j].Date) {
+			return entries[i].URL < entries[j].URL
+		}
+		return entries[i].Date.After(entries[j].Date)
+	})
+
+	years := make([]archiveYear, 0)
+	for _, entry := range entries {
+		if len(years) == 0 || years[len(years)-1].Year != entry.Date.Year() {
+			years = append(years, archiveYear{Year: entry.Date.Year()})
+		}
+		year := &years[len(years)-1]
+
+		if len(year.Months) == 0 || year.Months[len(year.Months)-1].Month != entry.Date.Month() {
+			year.Months = append(year.Months, archiveMonth{Month: entry.Date.Month()})
+		}
+		month := &year.Months[len(year.Months)-1]
+
+		month.Pages = append(month.Pages, entry)
+		month.Count++
+		year.Count++
+	}
+
+	return years
+}
+
+func renderArchive(p page) {
+	err := output.MkdirAll(filepath.Dir(p.OutPath))
+	if err != nil {
+		log.Printf("[gen/render/archive] unable to create directory for %s: %s", p.OutPath, err)
+		return
+	}
+
+	f, err := output.Create(p.OutPath)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", p.OutPath, err)
+		return
+	} else {
+		defer f.Close()
+
+		err = archiveTemplate.Execute(f, p)
+		if err != nil {
+			log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
+			return
+		} else {
+			log.Printf("[gen/render/file] rendered file %s", p.OutPath)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"mime"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+)
+
+// assetTypeDirectories route assets by extension under the type layout,
+// falling back to the mime type's major type for other extensions.
+var assetTypeDirectories = map[string]string{
+	".png": "img", ".jpg": "img", ".jpeg": "img", ".gif": "img", ".svg": "img",
+	".webp": "img", ".avif": "img", ".ico": "img", ".bmp": "img",
+	".css": "css",
+	".js":  "js", ".mjs": "js",
+	".woff": "fonts", ".woff2": "fonts", ".ttf": "fonts", ".otf": "fonts", ".eot": "fonts",
+	".mp4": "media", ".webm": "media", ".mp3": "media", ".ogg": "media", ".wav": "media",
+}
+
+var mimeTypeDirectories = map[string]string{
+	"image": "img",
+	"font":  "fonts",
+	"audio": "media",
+	"video": "media",
+}
+
+// movedAssets maps the url of each moved asset to where it was moved, and
+// movedOwners each place an asset was moved to back to the asset's url.
+var (
+	movedAssets   = make(map[string]string)
+	movedOwners   = make(map[string]string)
+	movedAssetsMu sync.Mutex
+)
+
+func init() {
+	registerTransform("assetLayout", func() bool { return len(movedAssets) > 0 }, rewriteAssetReferences)
+}
+
+// assetOutPath moves an asset's output path under the AssetLayout. The
+// default, preserve, keeps the content structure, flatten puts every asset
+// in assets/ and type routes them into img/, css/, js/, fonts/, media/ or
+// files/ by type, and versioned places each under a directory named for a
+// hash of its content from VersionedAssetPath. Files at the top of content,
+// like robots.txt, and in dot directories, like .well-known, stay where they
+// are. Assets that would be moved to a path another asset already took, like
+// a/logo.png and b/logo.png in assets/, get a short hash of their url before
+// the extension instead. Moved assets are recorded so references to them in
+// rendered pages are rewritten, but references inside css and js aren't.
+func assetOutPath(source, outPath string) string {
+	url := pageURL(outPath)
+	rel := strings.TrimPrefix(url, "/")
+	if !strings.Contains(rel, "/") || strings.HasPrefix(rel, ".") {
+		return outPath
+	}
+
+	name := path.Base(rel)
+	var moved string
+	switch cfg.AssetLayout {
+	case "flatten":
+		moved = path.Join("assets", name)
+	case "type":
+		moved = path.Join(assetTypeDirectory(name), name)
+	case "versioned":
+		hash, err := assetHash(source)
+		if err != nil {
+			log.Printf("[gen/process/file] unable to hash %s, leaving it unversioned: %s", source, err)
+			return outPath
+		}
+
+		moved = strings.NewReplacer("{hash}", hash, "{path}", rel, "{name}", name).Replace(cfg.VersionedAssetPath)
+		moved = strings.TrimPrefix(path.Clean("/"+moved), "/")
+	default:
+		return outPath
+	}
+
+	movedAssetsMu.Lock()
+	if owner, ok := movedOwners[moved]; ok && owner != url {
+		ext := path.Ext(moved)
+		sum := sha256.Sum256([]byte(url))
+		disambiguated := strings.TrimSuffix(moved, ext) + "-" + hex.EncodeToString(sum[:4]) + ext
+		log.Printf("[gen/process/file] %s is already moved to %s, moving %s to %s", owner, moved, url, disambiguated)
+		moved = disambiguated
+	}
+	movedOwners[moved] = url
+	movedAssets[url] = "/" + moved
+	movedAssetsMu.Unlock()
+
+	moved = filepath.Join("public", filepath.FromSlash(moved))
+	err := output.MkdirAll(filepath.Dir(moved))
+	if err != nil {
+		log.Printf("[gen/process/dir] unable to create directory %s: %s", filepath.Dir(moved), err)
+	}
+
+	return moved
+}
+
+// assetHash is the version of an asset in the versioned layout, a short
+// hash of its content so it only changes when the file does.
+func assetHash(source string) (string, error) {
+	f, err := openSource(source)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:4]), nil
+}
+
+func assetTypeDirectory(name string) string {
+	ext := strings.ToLower(path.Ext(name))
+	if dir, ok := assetTypeDirectories[ext]; ok {
+		return dir
+	}
+
+	major, _, _ := strings.Cut(mime.TypeByExtension(ext), "/")
+	if dir, ok := mimeTypeDirectories[major]; ok {
+		return dir
+	}
+
+	return "files"
+}
+
+// rewriteAssetReferences points src and href attributes at assets moved by
+// the AssetLayout.
+func rewriteAssetReferences(doc *html.Node, p *page) error {
+	url := pageURL(p.OutPath)
+	walkElements(doc, func(n *html.Node) {
+		for i, attr := range n.Attr {
+			if (attr.Key != "src" && attr.Key != "href") || !isLocalRef(attr.Val) {
+				continue
+			}
+
+			suffix := ""
+			if j := strings.IndexAny(attr.Val, "?#"); j >= 0 {
+				suffix = attr.Val[j:]
+			}
+
+			if moved, ok := movedAssets[resolveRef(url, attr.Val)]; ok {
+				n.Attr[i].Val = moved + suffix
+			}
+		}
+	})
+
+	return nil
+}
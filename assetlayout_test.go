@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestAssetLayoutKeepsCollidingAssetsApart(t *testing.T) {
+	tests := []struct {
+		layout string
+		dir    string
+	}{
+		{"flatten", "assets"},
+		{"type", "img"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.layout, func(t *testing.T) {
+			out := newTestSite(t, map[string]string{
+				"content/a/logo.png": "logo a",
+				"content/b/logo.png": "logo b",
+			})
+			cfg.AssetLayout = tt.layout
+
+			err := parseDirectoryContent("content", "gen")
+			if err != nil {
+				t.Fatalf("parseDirectoryContent: %s", err)
+			}
+
+			for _, problem := range report.Problems {
+				t.Errorf("unexpected problem: %s", problem)
+			}
+
+			for url, want := range map[string]string{"/a/logo.png": "logo a", "/b/logo.png": "logo b"} {
+				moved, ok := movedAssets[url]
+				if !ok {
+					t.Errorf("%s wasn't moved", url)
+					continue
+				}
+
+				b, err := out.ReadFile("public" + moved)
+				if err != nil {
+					t.Errorf("%s moved to %s: %s", url, moved, err)
+				} else if string(b) != want {
+					t.Errorf("%s moved to %s holding %q, want %q", url, moved, b, want)
+				}
+			}
+
+			if moved := movedAssets["/a/logo.png"]; moved != "/"+tt.dir+"/logo.png" {
+				t.Errorf("/a/logo.png moved to %s, want /%s/logo.png", moved, tt.dir)
+			}
+			if movedAssets["/a/logo.png"] == movedAssets["/b/logo.png"] {
+				t.Errorf("both assets moved to %s", movedAssets["/a/logo.png"])
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"log"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	reAssetRef = regexp.MustCompile(`(?i)<(img|script|link)\b[^>]*?\s(src|href)="([^"]*)"`)
+	reAnyRef   = regexp.MustCompile(`(?i)<[a-z][a-z0-9]*\b[^>]*?\s(?:src|href)="([^"]*)"`)
+)
+
+// conditionalAssets are the sources matching ConditionalAssets, keyed by
+// their url, held back until the rendered pages show which are referenced.
+var (
+	conditionalAssets   map[string]string
+	conditionalAssetsMu sync.Mutex
+)
+
+// deferAsset holds back a file matching ConditionalAssets, reporting whether
+// it was held back.
+func deferAsset(path, outPath string) bool {
+	url := pageURL(outPath)
+	if !matchesAny(cfg.ConditionalAssets, url) {
+		return false
+	}
+
+	conditionalAssetsMu.Lock()
+	conditionalAssets[url] = path
+	conditionalAssetsMu.Unlock()
+
+	return true
+}
+
+// copyReferencedAssets copies the held back assets referenced by at least
+// one rendered page, skipping the rest unless CopyUnreferencedAssets is set.
+func copyReferencedAssets(pages map[string]*page) {
+	if len(conditionalAssets) == 0 {
+		return
+	}
+
+	referenced := make(map[string]bool)
+	for _, page := range pages {
+		if page.Type == "" || !page.hasHTMLOutput() {
+			continue
+		}
+
+		rendered, err := output.ReadFile(page.OutPath)
+		if err != nil {
+			continue
+		}
+
+		url := pageURL(page.OutPath)
+		for _, match := range reAnyRef.FindAllStringSubmatch(string(rendered), -1) {
+			if isLocalRef(match[1]) {
+				referenced[resolveRef(url, match[1])] = true
+			}
+		}
+	}
+
+	for url, path := range conditionalAssets {
+		if !referenced[url] && !cfg.CopyUnreferencedAssets {
+			log.Printf("[gen/process/assets] skipping unreferenced %s", path)
+			continue
+		}
+
+		if !referenced[url] {
+			log.Printf("[gen/process/assets] copying unreferenced %s", path)
+		}
+
+		err := copyFile(path, filepath.Join("public", filepath.FromSlash(url)))
+		if err != nil {
+			log.Printf("[gen/process/assets] unable to copy %s: %s", path, err)
+		}
+	}
+}
+
+// isLocalRef reports whether a reference points at a file in the site rather
+// than another origin, a data uri or a fragment.
+func isLocalRef(ref string) bool {
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(ref, "//") {
+		return false
+	}
+
+	if i := strings.Index(ref, ":"); i >= 0 && !strings.ContainsAny(ref[:i], "/?#") {
+		return false
+	}
+
+	return true
+}
+
+// resolveRef maps a reference from the page at url to a site relative path.
+func resolveRef(url, ref string) string {
+	if i := strings.IndexAny(ref, "?#"); i >= 0 {
+		ref = ref[:i]
+	}
+
+	if !strings.HasPrefix(ref, "/") {
+		ref = path.Join(path.Dir(url), ref)
+	}
+
+	return path.Clean(ref)
+}
+
+// lintAssetReferences checks every image, script and stylesheet a rendered
+// page references resolves to a file in the output, reporting any that don't.
+func lintAssetReferences(pages map[string]*page) {
+	for _, page := range pages {
+		if page.Type == "" || !page.hasHTMLOutput() {
+			continue
+		}
+
+		rendered, err := output.ReadFile(page.OutPath)
+		if err != nil {
+			continue
+		}
+
+		url := pageURL(page.OutPath)
+		seen := make(map[string]bool)
+		for _, match := range reAssetRef.FindAllStringSubmatch(string(rendered), -1) {
+			tag, ref := strings.ToLower(match[1]), match[3]
+			if !isLocalRef(ref) || seen[ref] {
+				continue
+			}
+
+			seen[ref] = true
+
+			target := resolveRef(url, ref)
+			if !output.Exists(filepath.Join("public", filepath.FromSlash(target))) {
+				reportMissing("validate/assets", page.Path, "unresolved <%s> reference %s", tag, ref)
+			}
+		}
+	}
+}
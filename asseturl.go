@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// assetManifest maps asset names to their cache busted filenames from the
+// AssetManifest file, e.g. {"css/style.css": "css/style.3f2a1c.css"}
+var assetManifest map[string]string
+
+// loadAssetManifest reads the AssetManifest file when one is configured.
+func loadAssetManifest(path string) (map[string]string, error) {
+	manifest := make(map[string]string)
+	if path == "" {
+		return manifest, nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/assets] unable to open asset manifest: %s", err)
+	}
+
+	err = json.Unmarshal(b, &manifest)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/assets] unable to parse asset manifest %s: %s", path, err)
+	}
+
+	return manifest, nil
+}
+
+// asset builds the url of a static asset for templates, resolving it through
+// the asset manifest and prefixing it with AssetPath, so {{asset
+// "css/style.css"}} might become /static/css/style.3f2a1c.css
+func asset(name string) string {
+	name = strings.TrimPrefix(name, "/")
+	if hashed, ok := assetManifest[name]; ok {
+		name = strings.TrimPrefix(hashed, "/")
+	}
+
+	return strings.TrimSuffix(cfg.AssetPath, "/") + "/" + name
+}
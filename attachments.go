@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// attachmentsSuffix names the directory next to a page holding its
+// downloads, guide_files for guide.md.
+const attachmentsSuffix = "_files"
+
+// attachment is a file offered for download with a page.
+type attachment struct {
+	Name string
+	URL  string
+	Size int64
+}
+
+// attachmentDirs returns the names of the directories among inodes that hold
+// the attachments of a page beside them, so they aren't parsed as content.
+func attachmentDirs(inodes []fs.DirEntry) map[string]bool {
+	pages := make(map[string]bool)
+	for _, inode := range inodes {
+		_, processed := processor(inode.Name())
+		if name := inode.Name(); !inode.IsDir() && (filepath.Ext(name) == ".html" || isMarkdown(name) || processed) {
+			pages[strings.TrimSuffix(name, filepath.Ext(name))] = true
+		}
+	}
+
+	dirs := make(map[string]bool)
+	for _, inode := range inodes {
+		if name := inode.Name(); inode.IsDir() && strings.HasSuffix(name, attachmentsSuffix) && pages[strings.TrimSuffix(name, attachmentsSuffix)] {
+			dirs[name] = true
+		}
+	}
+
+	return dirs
+}
+
+// addAttachments copies the files in the page's attachments directory to the
+// output and lists them in Attachments, in name order. Directories inside it
+// are left out. Encrypted pages get none, since the files would be served
+// in the clear next to them, and a directory beside one is reported.
+func (p *page) addAttachments(path string) {
+	dir := strings.TrimSuffix(path, filepath.Ext(path)) + attachmentsSuffix
+	inodes, err := readSourceDir(dir)
+	if err != nil {
+		return
+	}
+
+	if p.Encrypted {
+		report.Add("process/attachments", path, "not copying %s, the page is password protected and its attachments wouldn't be", dir)
+		return
+	}
+
+	for _, inode := range inodes {
+		source := filepath.Join(dir, inode.Name())
+		if inode.IsDir() {
+			log.Printf("[gen/process/attachments] skipping directory %s, attachments can't be nested", source)
+			continue
+		}
+
+		info, err := statSource(source)
+		if err != nil {
+			log.Printf("[gen/process/attachments] unable to stat %s: %s", source, err)
+			continue
+		}
+
+		outPath := outputPath(source)
+		err = output.MkdirAll(filepath.Dir(outPath))
+		if err == nil {
+			err = copyFile(source, outPath)
+		}
+		if err != nil {
+			log.Printf("[gen/process/attachments] unable to copy %s: %s", source, err)
+			continue
+		}
+
+		p.Attachments = append(p.Attachments, attachment{
+			Name: inode.Name(),
+			URL:  pageURL(outPath),
+			Size: info.Size(),
+		})
+		log.Printf("[gen/process/attachments] copied %s for %s", source, p.Path)
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestAttachmentsSkippedOnPasswordPages(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"content/open.md":               "# Open\n",
+		"content/open_files/notes.txt":  "notes",
+		"content/guide.md":              "---\npassword: secret\n---\n# Guide\n",
+		"content/guide_files/notes.txt": "secret notes",
+	})
+
+	err := parseDirectoryContent("content", "gen")
+	if err != nil {
+		t.Fatalf("parseDirectoryContent: %s", err)
+	}
+
+	if !out.Exists("public/open_files/notes.txt") {
+		t.Errorf("attachment of open.md wasn't copied")
+	}
+	if out.Exists("public/guide_files/notes.txt") {
+		t.Errorf("attachment of the password protected guide.md was copied")
+	}
+	if p := pages["public/guide.html"]; p == nil || !p.Encrypted || len(p.Attachments) != 0 {
+		t.Errorf("guide.md is %+v, want it encrypted without attachments", p)
+	}
+	if len(report.Problems) != 1 || report.Problems[0].Phase != "process/attachments" {
+		t.Errorf("reported %v, want one process/attachments", report.Problems)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+type backlink struct {
+	URL     string
+	Title   string
+	Context string
+}
+
+const backlinkContextLength = 200
+
+var (
+	reBlockStart  = regexp.MustCompile(`(?i)<(p|li|td|th|h[1-6]|blockquote|dd|dt)\b[^>]*>`)
+	reBlockEnd    = regexp.MustCompile(`(?i)</(p|li|td|th|h[1-6]|blockquote|dd|dt)>`)
+	reAnchorEnd   = regexp.MustCompile(`(?i)</a>`)
+	reSentenceEnd = regexp.MustCompile(`[.!?]+(?:\s+|$)`)
+)
+
+// newBacklink records a link from the source page, with the sentence around
+// the link at offset in the source content when BacklinkContext is enabled.
+func newBacklink(source *page, offset int) backlink {
+	b := backlink{
+		URL:   pageLink(source.OutPath),
+		Title: source.Title,
+	}
+
+	if cfg.BacklinkContext {
+		b.Context = linkContext(string(source.Content), offset)
+	}
+
+	return b
+}
+
+// linkContext extracts the plain text sentence containing the link starting
+// at offset, falling back to the whole enclosing block when the link text
+// can't be found in any one sentence.
+func linkContext(content string, offset int) string {
+	start := 0
+	for _, match := range reBlockStart.FindAllStringIndex(content[:offset], -1) {
+		start = match[0]
+	}
+
+	end := len(content)
+	if match := reBlockEnd.FindStringIndex(content[offset:]); match != nil {
+		end = offset + match[1]
+	}
+
+	linkEnd := end
+	if match := reAnchorEnd.FindStringIndex(content[offset:]); match != nil {
+		linkEnd = offset + match[1]
+	}
+
+	block := strings.Join(strings.Fields(plainText(template.HTML(content[start:end]))), " ")
+	linkText := strings.Join(strings.Fields(plainText(template.HTML(content[offset:linkEnd]))), " ")
+
+	if linkText != "" {
+		last := 0
+		for _, match := range append(reSentenceEnd.FindAllStringIndex(block, -1), []int{len(block), len(block)}) {
+			sentence := strings.TrimSpace(block[last:match[1]])
+			if strings.Contains(sentence, linkText) {
+				return truncateWords(sentence, backlinkContextLength)
+			}
+			last = match[1]
+		}
+	}
+
+	return truncateWords(block, backlinkContextLength)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultBookTemplate lays out a book when the project doesn't have a
+// book.html template.
+const defaultBookTemplate = `<!doctype html>
+<html><head><meta charset="utf-8"><title>{{.Title}}</title></head>
+<body><h1>{{.Title}}</h1><nav class="gen-book-toc">{{.TOC}}</nav>{{.Content}}</body></html>`
+
+// book is the data the book template renders.
+type book struct {
+	Title   string
+	TOC     template.HTML
+	Content template.HTML
+	Site    *site
+}
+
+// chapter is one page of a book, at the depth of the page in the section.
+type chapter struct {
+	Page  *page
+	Depth int
+	ID    string
+}
+
+// writeBook parses the site and writes the pages of section, like /docs/,
+// as one printable document to BookPath. Parsing builds in memory, so the
+// book is written to disk on its own.
+func writeBook(section string) error {
+	err := parseOnly()
+	if err != nil {
+		return err
+	}
+
+	b, err := generateBook(section)
+	if err != nil {
+		return err
+	}
+
+	out := newDiskOutput()
+	err = out.MkdirAll(filepath.Dir(cfg.BookPath))
+	if err == nil {
+		err = out.WriteFile(cfg.BookPath, b)
+	}
+	if err != nil {
+		return fmt.Errorf("[gen/book/write] unable to write %s: %s", cfg.BookPath, err)
+	}
+
+	log.Printf("[gen/book/write] wrote %s", cfg.BookPath)
+	return nil
+}
+
+// generateBook stitches the pages of a section together in navigation order.
+// Each chapter's headings move down a level for every level it's nested in
+// the section, and its ids are prefixed with the chapter's and -- so they
+// stay unique, with links between the chapters pointing within the book.
+// Chapter ids are slugs of the page's url, which never hold --, numbered
+// when two pages slug the same like /a-b.html and /a/b.html.
+func generateBook(section string) ([]byte, error) {
+	dir := strings.Trim(section, "/")
+	node := siteContext.Nav
+	for _, name := range strings.Split(dir, "/") {
+		if name == "" {
+			continue
+		}
+
+		var found *navNode
+		for _, child := range node.Children {
+			if child.Name == name && len(child.Children) > 0 {
+				found = child
+			}
+		}
+		if found == nil {
+			return nil, fmt.Errorf("[gen/book/section] no section %s in the navigation", section)
+		}
+		node = found
+	}
+
+	byURL := make(map[string]*page, len(pages))
+	for _, page := range pages {
+		byURL[pageLink(page.OutPath)] = page
+	}
+
+	chapters := make([]chapter, 0)
+	taken := make(map[string]bool)
+	var collect func(n *navNode, depth int)
+	collect = func(n *navNode, depth int) {
+		// sections without an index page don't nest their pages any deeper
+		childDepth := depth
+		if page, ok := byURL[n.URL]; ok {
+			switch {
+			case page.Type != "MD":
+				log.Printf("[gen/book/chapter] leaving out %s, only markdown pages are supported", page.Path)
+			case page.Encrypted:
+				log.Printf("[gen/book/chapter] leaving out encrypted %s", page.Path)
+			default:
+				base := "chapter-" + slug(strings.TrimSuffix(pageURL(page.OutPath), ".html"))
+				id := base
+				for i := 1; taken[id]; i++ {
+					id = fmt.Sprintf("%s-%d", base, i)
+				}
+				taken[id] = true
+
+				chapters = append(chapters, chapter{Page: page, Depth: depth, ID: id})
+				childDepth = depth + 1
+			}
+		}
+
+		for _, child := range n.Children {
+			collect(child, childDepth)
+		}
+	}
+	collect(node, 0)
+
+	if len(chapters) == 0 {
+		return nil, fmt.Errorf("[gen/book/section] no markdown pages in %s", section)
+	}
+
+	chapterIDs := make(map[string]string, len(chapters))
+	for _, c := range chapters {
+		chapterIDs[pageLink(c.Page.OutPath)] = c.ID
+	}
+
+	// the table of contents nests chapters by depth, each with its h2s
+	type tocEntry struct {
+		node  *navNode
+		depth int
+	}
+	root := &navNode{}
+	stack := []tocEntry{{root, -1}}
+	var content strings.Builder
+	for _, c := range chapters {
+		body, headings, err := bookChapter(c, chapterIDs)
+		if err != nil {
+			return nil, fmt.Errorf("[gen/book/chapter] unable to add %s: %s", c.Page.Path, err)
+		}
+		fmt.Fprintf(&content, `<section class="gen-chapter" id="%s">%s</section>`, c.ID, body)
+
+		entry := &navNode{Title: c.Page.Title, URL: "#" + c.ID}
+		for _, h := range headings {
+			entry.Children = append(entry.Children, &navNode{Title: h.Title, URL: "#" + h.ID})
+		}
+
+		for stack[len(stack)-1].depth >= c.Depth {
+			stack = stack[:len(stack)-1]
+		}
+		parent := stack[len(stack)-1].node
+		parent.Children = append(parent.Children, entry)
+		stack = append(stack, tocEntry{entry, c.Depth})
+	}
+
+	var toc strings.Builder
+	writeBookTOC(&toc, root.Children)
+
+	t := template.Must(template.New("book").Parse(defaultBookTemplate))
+	path := templatePath("book.html")
+	if _, err := os.Stat(path); err == nil {
+		t, err = loadTemplate("book", path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	title := node.Title
+	if node == siteContext.Nav {
+		title = cfg.Title
+	}
+
+	var rendered bytes.Buffer
+	err := t.Execute(&rendered, book{
+		Title:   title,
+		TOC:     template.HTML(toc.String()),
+		Content: template.HTML(content.String()),
+		Site:    siteContext,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[gen/book/render] unable to render book: %s", err)
+	}
+
+	return rendered.Bytes(), nil
+}
+
+func writeBookTOC(b *strings.Builder, entries []*navNode) {
+	if len(entries) == 0 {
+		return
+	}
+
+	b.WriteString("<ul>")
+	for _, entry := range entries {
+		fmt.Fprintf(b, `<li><a href="%s">%s</a>`, entry.URL, template.HTMLEscapeString(entry.Title))
+		writeBookTOC(b, entry.Children)
+		b.WriteString("</li>")
+	}
+	b.WriteString("</ul>")
+}
+
+// bookChapter rewrites a chapter's content for the book, returning it with
+// the chapter's h2 headings for the table of contents. A chapter that
+// doesn't open with a heading is given one from its title.
+func bookChapter(c chapter, chapterIDs map[string]string) (string, []pageSection, error) {
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(string(c.Page.Content)), body)
+	if err != nil {
+		return "", nil, err
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	if first := firstElement(body); first == nil || headingLevel(first) == 0 {
+		heading := &html.Node{Type: html.ElementNode, Data: "h1", DataAtom: atom.H1}
+		heading.AppendChild(&html.Node{Type: html.TextNode, Data: c.Page.Title})
+		body.InsertBefore(heading, body.FirstChild)
+	}
+
+	taken := make(map[string]bool)
+	walkElements(body, func(n *html.Node) {
+		if id := getAttr(n, "id"); id != "" {
+			taken[id] = true
+		}
+	})
+
+	headings := make([]pageSection, 0)
+	walkElements(body, func(n *html.Node) {
+		if id := getAttr(n, "id"); id != "" {
+			setAttr(n, "id", c.ID+"--"+id)
+		}
+
+		if n.DataAtom == atom.A {
+			setAttr(n, "href", bookHref(getAttr(n, "href"), pageURL(c.Page.OutPath), chapterIDs))
+		}
+
+		// the book is written elsewhere, so relative references are made
+		// site relative
+		if src := getAttr(n, "src"); src != "" && !strings.HasPrefix(src, "/") && !strings.Contains(src, ":") {
+			setAttr(n, "src", resolveRef(pageURL(c.Page.OutPath), src))
+		}
+
+		level := headingLevel(n)
+		if level == 0 {
+			return
+		}
+
+		if level == 2 {
+			id := getAttr(n, "id")
+			if id == "" {
+				base := slug(nodeText(n))
+				id = base
+				for i := 1; taken[id]; i++ {
+					id = fmt.Sprintf("%s-%d", base, i)
+				}
+				taken[id] = true
+
+				id = c.ID + "--" + id
+				setAttr(n, "id", id)
+			}
+			headings = append(headings, pageSection{ID: id, Title: nodeText(n)})
+		}
+
+		if level += c.Depth; level > 6 {
+			level = 6
+		}
+		n.Data = "h" + strconv.Itoa(level)
+		n.DataAtom = atom.Lookup([]byte(n.Data))
+	})
+
+	var b strings.Builder
+	for n := body.FirstChild; n != nil; n = n.NextSibling {
+		err := html.Render(&b, n)
+		if err != nil {
+			return "", nil, err
+		}
+	}
+
+	return b.String(), headings, nil
+}
+
+// bookHref points links to anchors and pages in the book at their place in
+// it, leaving links out of the book as they are.
+func bookHref(href, current string, chapterIDs map[string]string) string {
+	if href == "" {
+		return href
+	}
+
+	if strings.Contains(href, ":") {
+		return href
+	}
+
+	target, fragment, _ := strings.Cut(href, "#")
+	if target == "" {
+		target = current
+	}
+	target = resolveRef(current, target)
+
+	id, ok := chapterIDs[canonicalURL(target)]
+	if !ok {
+		if strings.HasPrefix(href, "/") || strings.HasPrefix(href, "#") {
+			return href
+		}
+		return target
+	}
+
+	if fragment != "" {
+		return "#" + id + "--" + fragment
+	}
+
+	return "#" + id
+}
+
+func firstElement(n *html.Node) *html.Node {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			return c
+		}
+	}
+
+	return nil
+}
+
+func headingLevel(n *html.Node) int {
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		return int(n.Data[1] - '0')
+	}
+
+	return 0
+}
+
+func setAttr(n *html.Node, key, val string) {
+	for i, attr := range n.Attr {
+		if attr.Key == key {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+
+	n.Attr = append(n.Attr, html.Attribute{Key: key, Val: val})
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+
+	return strings.Join(strings.Fields(b.String()), " ")
+}
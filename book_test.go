@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestBookIDsAreUnique(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/docs/index.md":   "# Docs\n\n## Install\n\n## Install\n",
+		"content/docs/a-b.md":     "# A B\n\n## Intro\n\nSee [the other intro](a/b.md#intro).\n",
+		"content/docs/a/index.md": "# A\n",
+		"content/docs/a/b.md":     "# B\n\n<h2 id=\"intro\">Intro</h2>\n",
+		"content/docs/install.md": "# Install\n",
+	})
+
+	_, err := parseSite()
+	if err != nil {
+		t.Fatalf("parseSite: %s", err)
+	}
+
+	b, err := generateBook("/docs/")
+	if err != nil {
+		t.Fatalf("generateBook: %s", err)
+	}
+
+	ids := make(map[string]bool)
+	for _, match := range regexp.MustCompile(`\sid="([^"]*)"`).FindAllStringSubmatch(string(b), -1) {
+		if ids[match[1]] {
+			t.Errorf("id %s is used more than once", match[1])
+		}
+		ids[match[1]] = true
+	}
+
+	for _, href := range regexp.MustCompile(`href="#([^"]*)"`).FindAllStringSubmatch(string(b), -1) {
+		if !ids[href[1]] {
+			t.Errorf("link to #%s has no target", href[1])
+		}
+	}
+
+	// the link is to the intro of the chapter slugging the same as its own
+	link := regexp.MustCompile(`<section class="gen-chapter" id="([^"]*)">(?:[^<]|<[^s])*?<a href="#([^"]*)"[^>]*>the other intro`).FindStringSubmatch(string(b))
+	if link == nil {
+		t.Fatalf("no link to the other intro in the book:\n%s", b)
+	}
+	if link[2] == link[1]+"--intro" || !strings.HasSuffix(link[2], "--intro") {
+		t.Errorf("the link to the other intro in %s points at #%s", link[1], link[2])
+	}
+
+	for _, want := range []string{"chapter-docs-a-b", "chapter-docs-a-b-1", "chapter-docs-install", "chapter-docs-index--install", "chapter-docs-index--install-1"} {
+		if !ids[want] {
+			t.Errorf("no id %s in the book", want)
+		}
+	}
+}
+
+func TestWriteBook(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/docs/index.md": "# Docs\n",
+		"content/docs/page.md":  "# Page\n",
+	})
+	cfg.BookPath = filepath.Join("out", "book.html")
+
+	err := writeBook("/docs/")
+	if err != nil {
+		t.Fatalf("writeBook: %s", err)
+	}
+
+	info, err := os.Stat(cfg.BookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("book written with mode %s, want 0600 like the rest of the output", info.Mode().Perm())
+	}
+
+	b, err := os.ReadFile(cfg.BookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "Page") {
+		t.Errorf("book is missing the page:\n%s", b)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import "strings"
+
+// matchesBuildTags reports whether the page is part of this build. Pages
+// without buildTags in their front matter always are, others only when -tags
+// names one of them.
+func (p *page) matchesBuildTags() bool {
+	tags := parseList(p.Meta["buildtags"])
+	if len(tags) == 0 {
+		return true
+	}
+
+	for _, requested := range strings.Split(*flagTags, ",") {
+		requested = strings.TrimSpace(requested)
+		for _, tag := range tags {
+			if requested != "" && strings.EqualFold(tag, requested) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+const (
+	markdownCacheDir     = ".cache/markdown"
+	markdownCacheVersion = 1
+)
+
+// cacheWrites is turned off by -check and -validate, which read the caches
+// but leave them as they are.
+var cacheWrites = true
+
+// writeCacheEntry stores an entry at path in the cache directory dir, unless
+// cache writes are off.
+func writeCacheEntry(dir, path string, b []byte) error {
+	if !cacheWrites {
+		return nil
+	}
+
+	err := os.MkdirAll(dir, 0700)
+	if err == nil {
+		err = os.WriteFile(path, b, 0600)
+	}
+
+	return err
+}
+
+type markdownCacheEntry struct {
+	HTML template.HTML
+	Info markdownInfo
+}
+
+// markdownCacheKey hashes the source along with everything that changes how
+// it renders, so changing the markdown config invalidates old entries.
+func markdownCacheKey(md []byte, options markdownOptions) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%d\x00%d\x00", markdownCacheVersion, options.Extensions, options.HTMLFlags)
+	h.Write(md)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// cachedMarkdown2html renders markdown through the on disk cache when
+// MarkdownCache is enabled, skipping the parse and render for unchanged
+// sources.
+func cachedMarkdown2html(md []byte, options markdownOptions) (template.HTML, markdownInfo) {
+	if !cfg.MarkdownCache {
+		return markdown2html(md, options)
+	}
+
+	path := filepath.Join(markdownCacheDir, markdownCacheKey(md, options)+".json")
+
+	if b, err := os.ReadFile(path); err == nil {
+		var entry markdownCacheEntry
+		if err := json.Unmarshal(b, &entry); err == nil {
+			return entry.HTML, entry.Info
+		}
+	}
+
+	html, info := markdown2html(md, options)
+
+	b, err := json.Marshal(markdownCacheEntry{HTML: html, Info: info})
+	if err == nil {
+		err = writeCacheEntry(markdownCacheDir, path, b)
+	}
+	if err != nil {
+		log.Printf("[gen/cache/markdown] unable to write cache entry %s: %s", path, err)
+	}
+
+	return html, info
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const outputManifestPath = ".cache/manifest.json"
+
+// outputChanges lists the output files, relative to public, that differ from
+// the previous build.
+type outputChanges struct {
+	Added    []string `json:"added"`
+	Modified []string `json:"modified"`
+	Deleted  []string `json:"deleted"`
+}
+
+// hashingOutput wraps an outputFS, recording a content hash for every file
+// written through it alongside the hashes from the previous build.
+type hashingOutput struct {
+	outputFS
+
+	mu       sync.Mutex
+	Hashes   map[string]string
+	Previous map[string]string
+}
+
+func newHashingOutput(out outputFS) *hashingOutput {
+	previous := make(map[string]string)
+	if b, err := os.ReadFile(outputManifestPath); err == nil {
+		err = json.Unmarshal(b, &previous)
+		if err != nil {
+			log.Printf("[gen/changes] ignoring unreadable manifest %s: %s", outputManifestPath, err)
+			previous = make(map[string]string)
+		}
+	}
+
+	return &hashingOutput{outputFS: out, Hashes: make(map[string]string), Previous: previous}
+}
+
+func manifestKey(path string) string {
+	return strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "public/")
+}
+
+func (h *hashingOutput) record(path string, data []byte) {
+	sum := sha256.Sum256(data)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.Hashes[manifestKey(path)] = hex.EncodeToString(sum[:])
+}
+
+// Keep carries the previous hash over for a file left untouched, falling
+// back to hashing it when the previous build didn't record one.
+func (h *hashingOutput) Keep(path string) error {
+	key := manifestKey(path)
+
+	h.mu.Lock()
+	hash, ok := h.Previous[key]
+	if ok {
+		h.Hashes[key] = hash
+	}
+	h.mu.Unlock()
+
+	if ok {
+		return h.outputFS.Keep(path)
+	}
+
+	data, err := h.outputFS.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	h.record(path, data)
+	return h.outputFS.Keep(path)
+}
+
+// Link records the hash of the linked file, the same as its target's.
+func (h *hashingOutput) Link(target, path string, symbolic bool) error {
+	err := h.outputFS.Link(target, path, symbolic)
+	if err != nil {
+		return err
+	}
+
+	data, err := h.outputFS.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	h.record(path, data)
+	return nil
+}
+
+func (h *hashingOutput) Create(path string) (io.WriteCloser, error) {
+	f, err := h.outputFS.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hashingFile{WriteCloser: f, output: h, path: path}, nil
+}
+
+func (h *hashingOutput) WriteFile(path string, data []byte) error {
+	err := h.outputFS.WriteFile(path, data)
+	if err == nil {
+		h.record(path, data)
+	}
+
+	return err
+}
+
+// hashingFile tees writes into a buffer so the file can be hashed on close.
+type hashingFile struct {
+	io.WriteCloser
+	buf    bytes.Buffer
+	output *hashingOutput
+	path   string
+}
+
+func (f *hashingFile) Write(b []byte) (int, error) {
+	f.buf.Write(b)
+	return f.WriteCloser.Write(b)
+}
+
+func (f *hashingFile) Close() error {
+	err := f.WriteCloser.Close()
+	if err == nil {
+		f.output.record(f.path, f.buf.Bytes())
+	}
+
+	return err
+}
+
+// diffManifests compares the hashes from two builds. Files only in previous
+// were not written by this build and count as deleted.
+func diffManifests(previous, current map[string]string) outputChanges {
+	changes := outputChanges{
+		Added:    make([]string, 0),
+		Modified: make([]string, 0),
+		Deleted:  make([]string, 0),
+	}
+
+	for path, hash := range current {
+		old, ok := previous[path]
+		if !ok {
+			changes.Added = append(changes.Added, path)
+		} else if old != hash {
+			changes.Modified = append(changes.Modified, path)
+		}
+	}
+
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changes.Deleted = append(changes.Deleted, path)
+		}
+	}
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Modified)
+	sort.Strings(changes.Deleted)
+
+	return changes
+}
+
+// writeChanges diffs this build against the stored manifest, writes the
+// changes to ChangesFile and replaces the manifest for the next build.
+func writeChanges(h *hashingOutput) error {
+	current := h.Hashes
+	changes := diffManifests(h.Previous, current)
+
+	b, err := json.MarshalIndent(changes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(cfg.ChangesFile, b, 0600)
+	if err != nil {
+		return err
+	}
+
+	b, err = json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(outputManifestPath), 0700)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(outputManifestPath, b, 0600)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[gen/changes] %d added, %d modified, %d deleted, written to %s", len(changes.Added), len(changes.Modified), len(changes.Deleted), cfg.ChangesFile)
+
+	return nil
+}
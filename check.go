@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// checkOutput builds the site in memory and compares it with the public
+// directory on disk, for sites that commit their output, returning the exit
+// code. It fails listing every file that differs, is missing from public or
+// is in public without being built, so a stale commit is caught before it
+// lands. Hooks don't run and nothing is written, caches are read but not
+// stored to.
+//
+// The site's BuildTime changes with every build, so the site is built twice
+// with BuildTimes apart in every field, and the parts of a file that differ
+// between the two builds aren't compared.
+func checkOutput() int {
+	cacheWrites = false
+
+	now := time.Now()
+	pinnedBuildTime = now
+	built := newMemoryOutput()
+	err := build(built)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	// encryption salts every build differently, so encrypted pages are only
+	// checked to exist
+	encrypted := make(map[string]bool)
+	for _, page := range pages {
+		if page.Encrypted {
+			encrypted[built.key(page.OutPath)] = true
+		}
+	}
+
+	log.Printf("[gen/check] building again with a later build time to find what depends on it")
+	resetSite()
+	pinnedBuildTime = now.AddDate(1, 1, 1).Add(time.Hour + time.Minute + time.Second + time.Millisecond)
+	later := newMemoryOutput()
+	err = build(later)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	differing, err := diffOutput(built.Files, later.Files, os.DirFS("public"), encrypted)
+	if err != nil {
+		log.Printf("[gen/check] unable to read public: %s", err)
+		return 1
+	}
+
+	for _, d := range differing {
+		log.Printf("[gen/check] public/%s %s", d.path, d.reason)
+	}
+
+	if len(differing) > 0 {
+		log.Printf("[gen/check] public is out of date, %d files differ, rebuild and commit it", len(differing))
+		return 1
+	}
+
+	log.Printf("[gen/check] public is up to date")
+	return 0
+}
+
+// outputDiff is one file where the built output and public disagree.
+type outputDiff struct {
+	path   string
+	reason string
+}
+
+// diffOutput compares the regular files of the built output with those in
+// public, in path order, without comparing the contents of those in skip.
+// The parts of a built file that differ in later, the same site built at a
+// later BuildTime, aren't compared.
+func diffOutput(built, later, public fs.FS, skip map[string]bool) ([]outputDiff, error) {
+	builtFiles, err := regularFiles(built)
+	if err != nil {
+		return nil, err
+	}
+
+	publicFiles, err := regularFiles(public)
+	if err != nil {
+		return nil, err
+	}
+
+	differing := make([]outputDiff, 0)
+	for path := range builtFiles {
+		if !publicFiles[path] {
+			differing = append(differing, outputDiff{path, "is missing"})
+			continue
+		}
+
+		if skip[path] {
+			continue
+		}
+
+		want, err := fs.ReadFile(built, path)
+		if err != nil {
+			return nil, err
+		}
+
+		got, err := fs.ReadFile(public, path)
+		if err != nil {
+			return nil, err
+		}
+
+		if bytes.Equal(want, got) {
+			continue
+		}
+
+		other, err := fs.ReadFile(later, path)
+		if err != nil || !equalOutsideBuildTime(want, other, got) {
+			differing = append(differing, outputDiff{path, "differs"})
+		}
+	}
+
+	for path := range publicFiles {
+		if !builtFiles[path] {
+			differing = append(differing, outputDiff{path, "isn't built anymore"})
+		}
+	}
+
+	sort.Slice(differing, func(i, j int) bool {
+		return differing[i].path < differing[j].path
+	})
+
+	return differing, nil
+}
+
+// buildTimeWindow bounds how many words the two builds run apart before
+// agreeing again, past the longest formatted BuildTime, and buildTimeSync is
+// how many they have to agree on to be taken as back in step.
+const (
+	buildTimeWindow = 64
+	buildTimeSync   = 2
+)
+
+// equalOutsideBuildTime reports whether got matches want everywhere want is
+// the same as other, built at a different BuildTime. Files are compared a
+// word at a time, numbers and letters or a single other byte, so a BuildTime
+// matches as a whole even where its digits happen to agree. Where want and
+// other differ got can hold up to buildTimeWindow words of anything, so
+// content sharing a line with a BuildTime is still compared.
+func equalOutsideBuildTime(want, other, got []byte) bool {
+	segments, ok := sharedSegments(splitWords(want), splitWords(other))
+	if !ok {
+		return false
+	}
+
+	words := splitWords(got)
+	if len(segments) == 1 {
+		return wordsEqual(words, segments[0])
+	}
+
+	first, last := segments[0], segments[len(segments)-1]
+	if len(words) < len(first)+len(last) || !wordsEqual(words[:len(first)], first) || !wordsEqual(words[len(words)-len(last):], last) {
+		return false
+	}
+
+	rest := words[len(first) : len(words)-len(last)]
+	for _, segment := range segments[1 : len(segments)-1] {
+		i := indexWords(rest[:min(len(rest), buildTimeWindow+len(segment))], segment)
+		if i < 0 {
+			return false
+		}
+
+		rest = rest[i+len(segment):]
+	}
+
+	return len(rest) <= buildTimeWindow
+}
+
+// sharedSegments splits want into the runs of words it shares with other, in
+// order, skipping what differs in between. It fails when the two don't agree
+// again within buildTimeWindow words.
+func sharedSegments(want, other [][]byte) ([][][]byte, bool) {
+	var segments [][][]byte
+	for {
+		n := 0
+		for n < len(want) && n < len(other) && bytes.Equal(want[n], other[n]) {
+			n++
+		}
+
+		segments = append(segments, want[:n])
+		want, other = want[n:], other[n:]
+		if len(want) == 0 && len(other) == 0 {
+			return segments, true
+		}
+
+		a, b, ok := resync(want, other)
+		if !ok {
+			return nil, false
+		}
+
+		want, other = want[a:], other[b:]
+	}
+}
+
+// resync returns the fewest words to skip in want and other for the two to
+// agree again, on buildTimeSync words or up to their ends.
+func resync(want, other [][]byte) (int, int, bool) {
+	for skipped := 1; skipped <= 2*buildTimeWindow; skipped++ {
+		for a := max(0, skipped-buildTimeWindow); a <= min(skipped, buildTimeWindow); a++ {
+			b := skipped - a
+			if a > len(want) || b > len(other) {
+				continue
+			}
+
+			w, o := want[a:], other[b:]
+			if len(w) < buildTimeSync || len(o) < buildTimeSync {
+				if wordsEqual(w, o) {
+					return a, b, true
+				}
+
+				continue
+			}
+
+			if wordsEqual(w[:buildTimeSync], o[:buildTimeSync]) {
+				return a, b, true
+			}
+		}
+	}
+
+	return 0, 0, false
+}
+
+// splitWords splits b into runs of letters and digits and the single bytes
+// between them.
+func splitWords(b []byte) [][]byte {
+	var words [][]byte
+	for len(b) > 0 {
+		n := 1
+		if isWordByte(b[0]) {
+			for n < len(b) && isWordByte(b[n]) {
+				n++
+			}
+		}
+
+		words = append(words, b[:n])
+		b = b[n:]
+	}
+
+	return words
+}
+
+func isWordByte(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func wordsEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// indexWords returns where sub first appears in words, or -1.
+func indexWords(words, sub [][]byte) int {
+	for i := 0; i+len(sub) <= len(words); i++ {
+		if wordsEqual(words[i:i+len(sub)], sub) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func regularFiles(fsys fs.FS) (map[string]bool, error) {
+	files := make(map[string]bool)
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Type().IsRegular() {
+			files[path] = true
+		}
+
+		return nil
+	})
+
+	return files, err
+}
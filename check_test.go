@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffOutput(t *testing.T) {
+	file := func(s string) *mapFile {
+		return &mapFile{Data: []byte(s), Mode: 0600}
+	}
+
+	built := mapFS{
+		"same.html":      file("<p>same</p>"),
+		"stamped.html":   file("<p>page</p>\n<footer>built 2026</footer>"),
+		"changed.html":   file("<p>new</p>\n<footer>built 2026</footer>"),
+		"missing.html":   file("<p>missing</p>"),
+		"encrypted.html": file("salted once"),
+		"inline.html":    file("<p>page</p> built 2026-10-14 07:12:22.768854288"),
+		"edited.html":    file("<p>new</p> built 2026-10-14 07:12:22.768854288"),
+	}
+	later := mapFS{
+		"same.html":      file("<p>same</p>"),
+		"stamped.html":   file("<p>page</p>\n<footer>built 2027</footer>"),
+		"changed.html":   file("<p>new</p>\n<footer>built 2027</footer>"),
+		"missing.html":   file("<p>missing</p>"),
+		"encrypted.html": file("salted twice"),
+		"inline.html":    file("<p>page</p> built 2027-11-15 08:13:23.769854288"),
+		"edited.html":    file("<p>new</p> built 2027-11-15 08:13:23.769854288"),
+	}
+	public := mapFS{
+		"same.html":      file("<p>same</p>"),
+		"stamped.html":   file("<p>page</p>\n<footer>built 2025</footer>"),
+		"changed.html":   file("<p>old</p>\n<footer>built 2025</footer>"),
+		"encrypted.html": file("salted before"),
+		"removed.html":   file("<p>removed</p>"),
+		"inline.html":    file("<p>page</p> built 2025-9-1 7:02:03.1"),
+		"edited.html":    file("<p>old</p> built 2025-9-1 7:02:03.1"),
+	}
+
+	differing, err := diffOutput(built, later, public, map[string]bool{"encrypted.html": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []outputDiff{
+		{"changed.html", "differs"},
+		{"edited.html", "differs"},
+		{"missing.html", "is missing"},
+		{"removed.html", "isn't built anymore"},
+	}
+	if !reflect.DeepEqual(differing, want) {
+		t.Errorf("diffOutput = %v, want %v", differing, want)
+	}
+}
+
+func TestCheckOutputIgnoresBuildTimeAndLeavesCaches(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/page.md":        "# Page\n",
+		"template/markdown.html": "<html><body>{{.Content}}\n<footer>built {{.Site.BuildTime}}</footer></body></html>",
+	})
+	cfg.MarkdownCache = true
+	t.Cleanup(func() {
+		cacheWrites = true
+		pinnedBuildTime = time.Time{}
+	})
+
+	err := build(diskOutput{})
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	err = os.RemoveAll(".cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resetSite()
+	if code := checkOutput(); code != 0 {
+		t.Errorf("checkOutput = %d, want public to be up to date", code)
+	}
+
+	if _, err := os.Stat(".cache"); !os.IsNotExist(err) {
+		t.Errorf("checkOutput wrote to .cache")
+	}
+}
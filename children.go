@@ -0,0 +1,45 @@
+package main
+
+import (
+	"path"
+)
+
+// linkChildren gives every section index page the pages directly in its
+// section and the index pages of its subsections as Children, for section
+// overviews. They're ordered by weight then title, or newest first with
+// sort: date in the index page's front matter. Hidden pages are left out.
+func linkChildren(pages map[string]*page) {
+	sections := make(map[string]*page)
+	for _, p := range pages {
+		if p.Type == "" || !p.hasHTMLOutput() {
+			continue
+		}
+
+		if url := pageURL(p.OutPath); path.Base(url) == "index.html" {
+			p.Children = make([]*page, 0)
+			sections[path.Dir(url)] = p
+		}
+	}
+
+	for _, page := range pages {
+		if page.Type == "" || page.Hidden || !page.hasHTMLOutput() {
+			continue
+		}
+
+		dir := path.Dir(pageURL(page.OutPath))
+		if path.Base(pageURL(page.OutPath)) == "index.html" {
+			if dir == "/" {
+				continue
+			}
+			dir = path.Dir(dir)
+		}
+
+		if section, ok := sections[dir]; ok && section != page {
+			section.Children = append(section.Children, page)
+		}
+	}
+
+	for _, section := range sections {
+		sortPages(section.Children, section.Meta["sort"])
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// collection is a named, curated list of pages selected by url glob.
+type collection struct {
+	// Include are the url globs of the pages in the collection.
+	Include []string
+
+	// Sort orders the collection by weight then title like the navigation,
+	// the default, or by date newest first like the feed.
+	Sort string
+}
+
+// buildCollections selects the pages in each configured collection, exposed
+// to templates as .Site.Collections.<name>
+func buildCollections(pages map[string]*page, collections map[string]collection) map[string][]*page {
+	built := make(map[string][]*page, len(collections))
+	for name, c := range collections {
+		selected := make([]*page, 0)
+		for _, page := range pages {
+			if page.Type == "" || !matchesAny(c.Include, pageURL(page.OutPath)) {
+				continue
+			}
+
+			selected = append(selected, page)
+		}
+
+		sortPages(selected, c.Sort)
+		built[name] = selected
+	}
+
+	return built
+}
+
+// sortPages orders pages by "date", newest first, or by weight then title.
+func sortPages(pages []*page, by string) {
+	sort.Slice(pages, func(i, j int) bool {
+		a, b := pages[i], pages[j]
+		switch {
+		case by == "date" && !a.Date.Equal(b.Date):
+			return a.Date.After(b.Date)
+		case by != "date" && a.Weight != b.Weight:
+			return a.Weight < b.Weight
+		case by != "date" && strings.ToLower(a.Title) != strings.ToLower(b.Title):
+			return strings.ToLower(a.Title) < strings.ToLower(b.Title)
+		}
+
+		return a.OutPath < b.OutPath
+	})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"sync"
+)
+
+// ioSemaphore bounds how many file operations run at once.
+type ioSemaphore chan struct{}
+
+func newIOSemaphore(n int) ioSemaphore {
+	if n < 1 {
+		n = 1
+	}
+
+	return make(ioSemaphore, n)
+}
+
+func (s ioSemaphore) acquire() {
+	s <- struct{}{}
+}
+
+func (s ioSemaphore) release() {
+	<-s
+}
+
+// forEachPage runs fn over every page with at most MaxConcurrency running at
+// once. With MaxConcurrency of 1 pages are handled one after the other on the
+// calling goroutine, as they were before rendering was parallel.
+func forEachPage(pages map[string]*page, fn func(*page)) {
+	if cfg.MaxConcurrency <= 1 {
+		for _, page := range pages {
+			fn(page)
+		}
+		return
+	}
+
+	sem := newIOSemaphore(cfg.MaxConcurrency)
+
+	var wg sync.WaitGroup
+	for _, p := range pages {
+		wg.Add(1)
+		sem.acquire()
+
+		go func(p *page) {
+			defer wg.Done()
+			defer sem.release()
+
+			fn(p)
+		}(p)
+	}
+
+	wg.Wait()
+}
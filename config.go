@@ -0,0 +1,369 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"runtime"
+	"time"
+)
+
+type config struct {
+	// BaseURL is the absolute site root, e.g. https://example.com
+	BaseURL string
+
+	// Title and Description describe the site in feeds, and Author names
+	// who wrote it there, the Title when it's empty.
+	Title       string
+	Description string
+	Author      string
+
+	// AssetPath is prefixed to the urls built by the asset template func,
+	// e.g. /static or https://cdn.example.com, and AssetManifest is a json
+	// file mapping asset names to cache busted filenames, as written by most
+	// bundlers.
+	AssetPath     string
+	AssetManifest string
+
+	// AssetLayout places copied assets in the output, preserve keeps the
+	// content structure, flatten moves them into assets/ and type into img/,
+	// css/, js/, fonts/, media/ and files/. References in pages follow.
+	AssetLayout string
+
+	// VersionedAssetPath is where the versioned AssetLayout puts each asset,
+	// with {hash} replaced by a hash of its content, {path} by its path in
+	// content and {name} by its file name. A changed file gets a new url,
+	// so the host can serve the versioned directory with
+	// Cache-Control: public, max-age=31536000, immutable while html keeps a
+	// short max-age or no-cache, e.g. a /assets/v* rule in _headers on
+	// Netlify and Cloudflare Pages or a location block in nginx.
+	VersionedAssetPath string
+
+	// DedupeFiles links copied files with the same content as one already
+	// copied to it instead of copying them again, with hardlink or symlink.
+	// Files are copied when the output's filesystem can't link them.
+	DedupeFiles string
+
+	// ConditionalAssets are url globs of files copied only when a rendered
+	// page references them, unless CopyUnreferencedAssets is set.
+	ConditionalAssets      []string
+	CopyUnreferencedAssets bool
+
+	// Favicon, AppleTouchIcon and ThemeColor generate the tags in
+	// .Site.IconTags, e.g. /favicon.svg, /apple-touch-icon.png and #336699
+	Favicon        string
+	AppleTouchIcon string
+	ThemeColor     string
+
+	// CriticalCSS is a stylesheet in the project inlined into every page as
+	// .CriticalCSS, with the stylesheets in the head loaded without blocking
+	// rendering, e.g. template/critical.css
+	CriticalCSS string
+
+	// Theme is a directory of fallback templates and assets, laid out like
+	// a project with template/ and static/ directories. Files in the
+	// project's TemplateDir and content/ override the theme's.
+	Theme string
+
+	// ContentArchive reads the content tree from a .zip, .tar or .tar.gz
+	// file instead of the content directory, e.g. content.zip
+	ContentArchive string
+
+	// TemplateDir is the directory templates and partials are read from,
+	// relative to the project.
+	TemplateDir string
+
+	// FooterlessLayouts are the layouts whose pages leave .Footer empty,
+	// like landing pages. Pages choose with footer front matter.
+	FooterlessLayouts []string
+
+	// ArchiveTemplate and EncryptedTemplate override the archive.html and
+	// encrypted.html templates in TemplateDir with paths in the project.
+	ArchiveTemplate   string
+	EncryptedTemplate string
+
+	// Recent lists that many of the most recently modified pages, newest
+	// first, on RecentPath with RecentTemplate, recent.html in TemplateDir
+	// by default, and with RecentFeed in an atom.xml beside it. Pages are
+	// dated by their source's modification time, or the last commit of it
+	// with RecentFromGit.
+	Recent         int
+	RecentPath     string
+	RecentTemplate string
+	RecentFeed     bool
+	RecentFromGit  bool
+
+	// LastCommit exposes the last commit of each page's source, its Author,
+	// Date and short Hash, as LastCommit, for "last edited by" lines. The
+	// history is read from git once a build, which is slow on long
+	// histories, and pages are built without it when git isn't available.
+	LastCommit bool
+
+	// AMP are url globs of pages that also get an AMP variant at amp/ below
+	// their url, rendered with AMPTemplate, amp.html in TemplateDir by
+	// default. Pages opt in or out with amp front matter.
+	AMP         []string
+	AMPTemplate string
+
+	// MarkdownExtensions are the source file extensions rendered as markdown.
+	MarkdownExtensions []string
+
+	// MaxPathSegmentLength caps the length in bytes of each part of an
+	// output path, shortening longer names with a hash.
+	MaxPathSegmentLength int
+
+	// Transliterate replaces accented latin letters in output paths with
+	// their plain ascii forms, so café becomes cafe. Turn it off to keep
+	// the letters, normalized to their composed form.
+	Transliterate bool
+
+	// URLStyle is the one form internal links to pages take, index links
+	// name the file, /docs/index.html, and pretty ones end at the
+	// directory, /docs/. Links are compared in it when resolving them.
+	URLStyle string
+
+	// SourcePrecedence orders the extensions of sources that generate the
+	// same output, like index.md and index.html, with the first winning.
+	// Defaults to the markdown extensions followed by .html.
+	SourcePrecedence []string
+
+	// DateFormats are the Go time layouts tried in order when parsing the
+	// date front matter field.
+	DateFormats []string
+
+	// Timezone is the IANA zone, like Europe/Paris, dates without a zone are
+	// read in and feed and sitemap dates are written in. Defaults to UTC.
+	Timezone string
+
+	// TitleCase derives titles like My Post from filenames like my_post for
+	// pages without a title in their front matter.
+	TitleCase bool
+
+	// TitleFromHeading titles markdown pages without a title in their front
+	// matter after their first h1, which StripTitleHeading removes from the
+	// content.
+	TitleFromHeading  bool
+	StripTitleHeading bool
+
+	// TitleCaseTitles applies title case rules to page titles, and
+	// TitleCaseHeadings to the headings in rendered pages. Pages can opt in
+	// or out with a titlecase front matter field.
+	TitleCaseTitles   bool
+	TitleCaseHeadings bool
+
+	// MarkdownCache keeps rendered markdown in .cache/markdown keyed by a hash
+	// of the source and markdown config to speed up rebuilds.
+	MarkdownCache bool
+
+	// ChangesFile, when set, is where the output files added, modified and
+	// deleted since the previous build are written as json. The hashes are
+	// kept between builds in .cache/manifest.json.
+	ChangesFile string
+
+	// BacklinkContext captures the sentence around each link on backlinks.
+	BacklinkContext bool
+
+	// CopyButtons adds a copy to clipboard button to code blocks.
+	CopyButtons bool
+
+	// LQIP gives local images a tiny blurred placeholder background shown
+	// while they load, cached in .cache/lqip.
+	LQIP bool
+
+	// OGImages generates a 1200x630 social card in og/ for every page
+	// without image front matter, its title in OGTextColor and the OGFont
+	// truetype font, Go Bold by default, over OGBackground, a colour like
+	// #1f2937 or an image in the project stretched to fit. Cards are cached
+	// in .cache/og by a hash of the title and style. Pages get an og:image
+	// meta tag for their card or image unless their template has one.
+	OGImages     bool
+	OGBackground string
+	OGTextColor  string
+	OGFont       string
+
+	// Transforms enables or disables post render html transforms by name,
+	// overriding their own config toggles.
+	Transforms map[string]bool
+
+	// SummaryDelimiter marks the end of the page summary, when AutoSummary is
+	// false only the summary front matter is used.
+	SummaryDelimiter string
+	AutoSummary      bool
+
+	// Lead gives the first paragraph of every markdown page class="lead" and
+	// exposes it as .Lead, pages choose with lead front matter.
+	Lead bool
+
+	// HTMLFlags toggles gomarkdown html renderer flags by name on top of the
+	// defaults, e.g. {"LazyLoadImages": true, "Smartypants": false}.
+	HTMLFlags map[string]bool
+
+	// CommonMarkStrict follows CommonMark's rules on blank lines and breaks
+	// as far as gomarkdown can, so an indented line after a paragraph stays
+	// text rather than starting a code block. ParserExtensions then toggles
+	// the spacing extensions by name on top, NoEmptyLineBeforeBlock,
+	// SpaceHeadings, HardLineBreak, BackslashLineBreak, NonBlockingSpace,
+	// TabSizeEight and EmptyLinesBreakList, e.g. {"HardLineBreak": true}.
+	CommonMarkStrict bool
+	ParserExtensions map[string]bool
+
+	// OnMissing is what happens when a page includes a snippet that isn't
+	// in includes or references an image, script or stylesheet that isn't in
+	// the output. warn, the default, reports it and leaves the reference as
+	// written, omit drops missing includes and says nothing, and fail reports
+	// it and fails the build once it's finished, without needing -strict.
+	OnMissing string
+
+	// Processors renders sources in formats gen doesn't know, by extension,
+	// with a shell command reading the source on stdin and writing html to
+	// stdout, e.g. {".org": "pandoc -f org -t html"}. The html is placed in
+	// the markdown template like a markdown page's, after any front matter
+	// is taken off. Pages whose command fails are skipped.
+	Processors map[string]string
+
+	// PreBuild and PostBuild are shell commands run before and after the
+	// build, a non-zero exit aborts.
+	PreBuild  string
+	PostBuild string
+
+	// Languages enables localised content, DefaultLanguage is served at the
+	// root and defaults to the first language.
+	Languages       []string
+	DefaultLanguage string
+
+	// LeftDelim and RightDelim are the action delimiters for html sources,
+	// for pages embedding other templating syntaxes like Vue's {{ }}.
+	LeftDelim  string
+	RightDelim string
+
+	// RawHTML are url globs of html sources written verbatim, without being
+	// executed as templates or transformed, like html: raw front matter.
+	RawHTML []string
+
+	// EditURL is a template rendered per page to link to its source, e.g.
+	// https://github.com/user/site/edit/main/content/{{.SourcePath}}
+	EditURL string
+
+	// RequiredFields are front matter fields every page must set, keys of
+	// SectionRequiredFields are content relative directories overriding them.
+	RequiredFields        []string
+	SectionRequiredFields map[string][]string
+
+	// Feed generates feeds listing the newest FeedLimit dated pages in each
+	// of FeedFormats, rss for public/feed.xml, atom for public/atom.xml and
+	// json for a JSON Feed in public/feed.json.
+	Feed        bool
+	FeedLimit   int
+	FeedFormats []string
+
+	// SectionFeeds also generates feeds in each top level section, like
+	// public/blog/feed.xml, listing only that section's pages.
+	SectionFeeds bool
+
+	// SitemapMaxURLs splits sitemap.xml into several files referenced from
+	// sitemap_index.xml once the site has more pages than this.
+	SitemapMaxURLs int
+
+	// MaxConcurrency bounds how many pages render at once, 1 renders them
+	// sequentially. Defaults to GOMAXPROCS.
+	MaxConcurrency int
+
+	// IORetries is how many times reading sources and writing output are
+	// retried on errors that might be transient, waiting IORetryDelay
+	// milliseconds before the first retry and doubling it after each.
+	IORetries    int
+	IORetryDelay int
+
+	// LLMsTxt generates public/llms.txt indexing the site for language models,
+	// LLMsFullTxt also generates llms-full.txt with the text of every page.
+	LLMsTxt     bool
+	LLMsFullTxt bool
+
+	// BookPath is where -book writes the combined document, laid out with
+	// book.html in TemplateDir when it exists.
+	BookPath string
+
+	// API writes the title, date, meta and rendered html of every page as
+	// json to public/api, mirroring the output tree for headless use.
+	API bool
+
+	// VerifyOutput re-reads every html page after the build, reporting any
+	// that are empty or don't parse.
+	VerifyOutput bool
+
+	// Context are site wide values available to templates as .Context,
+	// overridden by _context.json files in content directories for the
+	// pages below them and by context.<name> front matter.
+	Context map[string]interface{}
+
+	// Collections are named lists of pages selected by url glob, available
+	// to templates as .Site.Collections.<name>
+	Collections map[string]collection
+
+	// Menus are named navigation menus of {name, url, weight, parent}
+	// entries, which pages join with menu: <name> front matter, available
+	// to templates as .Site.Menus.<name>
+	Menus map[string][]menuEntry
+
+	// SitemapExclude and FeedExclude are url globs of pages to leave out,
+	// e.g. /legal/ or /tag/**
+	SitemapExclude []string
+	FeedExclude    []string
+
+	// RobotsRules generates public/robots.txt unless content/robots.txt exists.
+	RobotsRules []robotsRule
+}
+
+var cfg config
+
+func defaultConfig() config {
+	return config{
+		TemplateDir: "template",
+		BookPath:    "public/book.html",
+		RecentPath:  "public/recent/index.html",
+
+		VersionedAssetPath: "assets/v{hash}/{path}",
+
+		OGBackground: "#1f2937",
+		OGTextColor:  "#ffffff",
+
+		MarkdownExtensions: []string{".md", ".markdown"},
+		DateFormats:        []string{time.RFC3339, "2006-01-02"},
+
+		MaxPathSegmentLength: 100,
+		Transliterate:        true,
+
+		SummaryDelimiter: "<!--more-->",
+		AutoSummary:      true,
+
+		FeedLimit:      20,
+		FeedFormats:    []string{"rss"},
+		SitemapMaxURLs: 50000,
+
+		MaxConcurrency: runtime.GOMAXPROCS(0),
+		IORetryDelay:   100,
+
+		LeftDelim:  "{{",
+		RightDelim: "}}",
+	}
+}
+
+func loadConfig(path string) (config, error) {
+	c := defaultConfig()
+
+	f, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	} else if err != nil {
+		return c, fmt.Errorf("[gen/init/config] unable to open config %s: %s", path, err)
+	}
+
+	err = json.Unmarshal(f, &c)
+	if err != nil {
+		return c, fmt.Errorf("[gen/init/config] unable to parse config %s: %s", path, err)
+	}
+
+	return c, nil
+}
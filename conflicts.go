@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// pagesMu guards writes to pages so content can be parsed concurrently.
+var pagesMu sync.Mutex
+
+// sourceRank orders sources that generate the same output by extension, by
+// SourcePrecedence when set and otherwise the markdown extensions then html.
+// Lower ranks win.
+func sourceRank(path string) int {
+	precedence := cfg.SourcePrecedence
+	if len(precedence) == 0 {
+		precedence = append(append([]string(nil), cfg.MarkdownExtensions...), ".html")
+	}
+
+	ext := filepath.Ext(path)
+	for i, e := range precedence {
+		if strings.EqualFold(ext, e) {
+			return i
+		}
+	}
+
+	return len(precedence)
+}
+
+// addPage stores a parsed page, reporting when another source already
+// generates the same output and keeping the one with precedence. Ties go to
+// the source found later in the walk, matching the file last copied.
+func addPage(p *page) {
+	key := strings.Replace(filepath.ToSlash(p.OutPath), "/content", "", 1)
+
+	pagesMu.Lock()
+	defer pagesMu.Unlock()
+
+	if existing, ok := pages[key]; ok {
+		winner := p
+		if sourceRank(existing.Path) < sourceRank(p.Path) {
+			winner = existing
+		}
+
+		report.Add("parse/conflict", p.Path, "%s and %s both generate %s, keeping %s", existing.Path, p.Path, p.OutPath, winner.Path)
+		if winner == existing {
+			return
+		}
+	}
+
+	pages[key] = p
+}
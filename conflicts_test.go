@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestAddPageKeepsSourceWithPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		precedence []string
+		order      []string
+		want       string
+	}{
+		{"markdown after html", nil, []string{"index.html", "index.md"}, "index.md"},
+		{"markdown before html", nil, []string{"index.md", "index.html"}, "index.md"},
+		{"html first in SourcePrecedence", []string{".html", ".md"}, []string{"index.md", "index.html"}, "index.html"},
+		{"same extension goes to the later source", nil, []string{"index.md", "index.md"}, "index.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = defaultConfig()
+			cfg.SourcePrecedence = tt.precedence
+			pages = make(map[string]*page)
+			report.Reset()
+
+			for _, name := range tt.order {
+				addPage(&page{
+					Path:    filepath.Join("content", "docs", name),
+					OutPath: filepath.Join("public", "docs", "index.html"),
+				})
+			}
+
+			if len(pages) != 1 {
+				t.Fatalf("got %d pages, want 1", len(pages))
+			}
+			for _, p := range pages {
+				if got := filepath.Base(p.Path); got != tt.want {
+					t.Errorf("kept %s, want %s", got, tt.want)
+				}
+			}
+			if len(report.Problems) != 1 || report.Problems[0].Phase != "parse/conflict" {
+				t.Errorf("reported %v, want one parse/conflict", report.Problems)
+			}
+		})
+	}
+}
+
+func TestParseDirectoryWithIndexMarkdownAndHTML(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/docs/index.md":   "# From markdown\n",
+		"content/docs/index.html": "<p>From html</p>\n",
+	})
+
+	err := parseDirectoryContent("content", "gen")
+	if err != nil {
+		t.Fatalf("parseDirectoryContent: %s", err)
+	}
+
+	p, ok := pages["public/docs/index.html"]
+	if !ok {
+		t.Fatalf("no page for public/docs/index.html in %v", pages)
+	}
+	if p.Path != filepath.Join("content", "docs", "index.md") {
+		t.Errorf("kept %s, want the markdown source", p.Path)
+	}
+
+	conflicts := 0
+	for _, problem := range report.Problems {
+		if problem.Phase == "parse/conflict" {
+			conflicts++
+		}
+	}
+	if conflicts != 1 {
+		t.Errorf("reported %d conflicts, want 1: %v", conflicts, report.Problems)
+	}
+}
+
+func TestParseDirectoriesConcurrently(t *testing.T) {
+	sections := []string{"a", "b", "c", "d"}
+
+	files := make(map[string]string)
+	for _, section := range sections {
+		for i := 0; i < 10; i++ {
+			name := fmt.Sprintf("content/%s/page-%d.md", section, i)
+			files[name] = fmt.Sprintf("# %s %d\n\nSome *text* with a [link](/%s/page-%d.html).\n", section, i, section, (i+1)%10)
+		}
+		files["content/"+section+"/logo.png"] = "not really a png"
+	}
+	newTestSite(t, files)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(sections))
+	for _, section := range sections {
+		wg.Add(1)
+		go func(section string) {
+			defer wg.Done()
+			errs <- parseDirectoryContent(filepath.Join("content", section), section)
+		}(section)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	parsed := 0
+	for _, p := range pages {
+		if p.Type == "MD" {
+			parsed++
+		}
+	}
+	if want := len(sections) * 10; parsed != want {
+		t.Errorf("parsed %d markdown pages, want %d", parsed, want)
+	}
+	for _, section := range sections {
+		key := "public/" + section + "/page-0.html"
+		if _, ok := pages[key]; !ok {
+			t.Errorf("%s is missing from pages", key)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// sectionContextFile holds the context values for every page in its
+// directory and below, it isn't copied to the output.
+const sectionContextFile = "_context.json"
+
+var (
+	sectionContexts   = make(map[string]map[string]interface{})
+	sectionContextsMu sync.Mutex
+)
+
+// sectionContext reads the _context.json of a content directory, caching it
+// for the other pages in the section. Directories without one are empty.
+func sectionContext(dir string) map[string]interface{} {
+	sectionContextsMu.Lock()
+	defer sectionContextsMu.Unlock()
+
+	if values, ok := sectionContexts[dir]; ok {
+		return values
+	}
+
+	values := make(map[string]interface{})
+	path := filepath.Join(dir, sectionContextFile)
+	b, err := readSource(path)
+	if err == nil {
+		err = json.Unmarshal(b, &values)
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		report.Add("parse/context", path, "unable to read section context: %s", err)
+		values = make(map[string]interface{})
+	}
+
+	sectionContexts[dir] = values
+	return values
+}
+
+// pageContext merges the values available to a page as .Context, the
+// site's Context config, then each _context.json from content down to the
+// page's directory, then the page's own context.<name> front matter, with
+// later values taking precedence.
+func pageContext(path string, meta map[string]string) map[string]interface{} {
+	context := make(map[string]interface{}, len(cfg.Context))
+	for key, value := range cfg.Context {
+		context[key] = value
+	}
+
+	dirs := make([]string, 0)
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if _, ok := contentName(dir); !ok {
+			break
+		}
+		dirs = append(dirs, dir)
+		if filepath.Clean(dir) == "content" {
+			break
+		}
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for key, value := range sectionContext(dirs[i]) {
+			context[key] = value
+		}
+	}
+
+	for key, value := range meta {
+		if name, ok := strings.CutPrefix(key, "context."); ok && name != "" {
+			context[name] = value
+		}
+	}
+
+	return context
+}
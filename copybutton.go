@@ -0,0 +1,74 @@
+package main
+
+import (
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const copyButtonImport = `<style>
+.gen-code { position: relative; }
+.gen-copy { position: absolute; top: 0.5em; right: 0.5em; }
+</style>
+<script>
+document.addEventListener("click", (e) => {
+	if (!e.target.classList.contains("gen-copy")) return;
+	const code = e.target.parentElement.querySelector("code");
+	navigator.clipboard.writeText(code.innerText).then(() => {
+		e.target.textContent = "Copied";
+		setTimeout(() => { e.target.textContent = "Copy"; }, 2000);
+	});
+});
+</script>
+`
+
+func init() {
+	registerTransform("copyButtons", func() bool { return cfg.CopyButtons }, addCopyButtons)
+}
+
+// addCopyButtons wraps every block level code block with a copy to clipboard
+// button, loading the script that drives them only on pages that have one.
+// Inline code is left alone as it's never inside a <pre>.
+func addCopyButtons(doc *html.Node, p *page) error {
+	blocks := make([]*html.Node, 0)
+	walkElements(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Pre || hasClass(n.Parent, "gen-code") {
+			return
+		}
+
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode {
+				if c.DataAtom == atom.Code {
+					blocks = append(blocks, n)
+				}
+				return
+			}
+		}
+	})
+
+	if len(blocks) == 0 {
+		return nil
+	}
+
+	for _, pre := range blocks {
+		wrapper := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "div",
+			DataAtom: atom.Div,
+			Attr:     []html.Attribute{{Key: "class", Val: "gen-code"}},
+		}
+		button := &html.Node{
+			Type:     html.ElementNode,
+			Data:     "button",
+			DataAtom: atom.Button,
+			Attr:     []html.Attribute{{Key: "class", Val: "gen-copy"}, {Key: "type", Val: "button"}},
+		}
+		button.AppendChild(&html.Node{Type: html.TextNode, Data: "Copy"})
+
+		pre.Parent.InsertBefore(wrapper, pre)
+		pre.Parent.RemoveChild(pre)
+		wrapper.AppendChild(button)
+		wrapper.AppendChild(pre)
+	}
+
+	return appendToHead(doc, copyButtonImport)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// criticalCSS is the CriticalCSS file as a style element, read once per
+// build and given to every page as .CriticalCSS
+var criticalCSS template.HTML
+
+func init() {
+	registerTransform("deferStylesheets", func() bool { return criticalCSS != "" }, deferStylesheets)
+}
+
+// loadCriticalCSS reads the CriticalCSS file into a style element.
+func loadCriticalCSS(path string) (template.HTML, error) {
+	if path == "" {
+		return "", nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("[gen/init/css] unable to read critical css: %s", err)
+	}
+
+	if strings.Contains(strings.ToLower(string(b)), "</style") {
+		return "", fmt.Errorf("[gen/init/css] critical css %s can't contain </style", path)
+	}
+
+	return template.HTML("<style>" + string(b) + "</style>"), nil
+}
+
+// deferStylesheets loads the stylesheets in the head without blocking the
+// first render once the critical css is inlined, preloading them and
+// applying them when they arrive, with a noscript fallback.
+func deferStylesheets(doc *html.Node, p *page) error {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return nil
+	}
+
+	links := make([]*html.Node, 0)
+	walkElements(head, func(n *html.Node) {
+		if n.DataAtom == atom.Link && strings.EqualFold(getAttr(n, "rel"), "stylesheet") && getAttr(n, "href") != "" {
+			links = append(links, n)
+		}
+	})
+
+	for _, link := range links {
+		fallback := &html.Node{Type: html.ElementNode, Data: "noscript", DataAtom: atom.Noscript}
+		fallback.AppendChild(&html.Node{
+			Type:     html.ElementNode,
+			Data:     "link",
+			DataAtom: atom.Link,
+			Attr:     append([]html.Attribute(nil), link.Attr...),
+		})
+		link.Parent.InsertBefore(fallback, link.NextSibling)
+
+		setAttr(link, "rel", "preload")
+		setAttr(link, "as", "style")
+		setAttr(link, "onload", "this.onload=null;this.rel='stylesheet'")
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"sync"
+)
+
+var (
+	copiedFiles   = make(map[string]string)
+	copiedFilesMu sync.Mutex
+)
+
+// linkDuplicate links outPath to a file already copied this build with the
+// same content, as DedupeFiles sets, reporting whether it did. The first
+// copy of each content is recorded for the rest to link to. When linking
+// fails, like on filesystems without links, the file is copied as usual.
+func linkDuplicate(path, outPath string) bool {
+	hash, err := sourceHash(path)
+	if err != nil {
+		log.Printf("[gen/process/dedupe] unable to hash %s, copying it: %s", path, err)
+		return false
+	}
+
+	copiedFilesMu.Lock()
+	first, ok := copiedFiles[hash]
+	if !ok {
+		copiedFiles[hash] = outPath
+	}
+	copiedFilesMu.Unlock()
+
+	if !ok || first == outPath {
+		return false
+	}
+
+	err = output.Link(first, outPath, cfg.DedupeFiles == "symlink")
+	if err != nil {
+		log.Printf("[gen/process/dedupe] unable to link %s to %s, copying it: %s", outPath, first, err)
+		return false
+	}
+
+	log.Printf("[gen/process/dedupe] linked %s to %s", outPath, first)
+	return true
+}
+
+func sourceHash(path string) (string, error) {
+	f, err := openSource(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
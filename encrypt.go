@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"html/template"
+	"os"
+)
+
+const (
+	encryptionIterations = 100000
+	encryptionKeyLength  = 32
+)
+
+// defaultEncryptedTemplate prompts for the page password and decrypts the
+// payload in the browser using WebCrypto, replacing itself with the content.
+const defaultEncryptedTemplate = `<div class="gen-encrypted" data-salt="{{.Salt}}" data-iv="{{.IV}}" data-payload="{{.Payload}}" data-iterations="{{.Iterations}}">
+<form onsubmit="genDecrypt(this); return false;">
+<input type="password" placeholder="Password" autofocus>
+<button type="submit">Unlock</button>
+<p class="gen-encrypted-error" hidden>Incorrect password</p>
+</form>
+</div>
+<script>
+async function genDecrypt(form) {
+	const root = form.parentElement;
+	const decode = (s) => Uint8Array.from(atob(s), (c) => c.charCodeAt(0));
+	const material = await crypto.subtle.importKey("raw", new TextEncoder().encode(form.querySelector("input").value), "PBKDF2", false, ["deriveKey"]);
+	const key = await crypto.subtle.deriveKey(
+		{ name: "PBKDF2", hash: "SHA-256", salt: decode(root.dataset.salt), iterations: parseInt(root.dataset.iterations) },
+		material, { name: "AES-GCM", length: 256 }, false, ["decrypt"]);
+	try {
+		const plain = await crypto.subtle.decrypt({ name: "AES-GCM", iv: decode(root.dataset.iv) }, key, decode(root.dataset.payload));
+		root.outerHTML = new TextDecoder().decode(plain);
+	} catch (e) {
+		form.querySelector(".gen-encrypted-error").hidden = false;
+	}
+}
+</script>
+`
+
+type encryptedContent struct {
+	Salt       string
+	IV         string
+	Payload    string
+	Iterations int
+}
+
+// encryptContent encrypts rendered page content with AES-256-GCM using a key
+// derived from the password, returning the unlock form that replaces it.
+func encryptContent(content template.HTML, password string) (template.HTML, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, 12)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", fmt.Errorf("[gen/parse/encrypt] unable to generate salt: %s", err)
+	}
+	_, err = rand.Read(iv)
+	if err != nil {
+		return "", fmt.Errorf("[gen/parse/encrypt] unable to generate iv: %s", err)
+	}
+
+	key := pbkdf2SHA256([]byte(password), salt, encryptionIterations, encryptionKeyLength)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", fmt.Errorf("[gen/parse/encrypt] unable to create cipher: %s", err)
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("[gen/parse/encrypt] unable to create cipher: %s", err)
+	}
+
+	payload := gcm.Seal(nil, iv, []byte(content), nil)
+
+	var result bytes.Buffer
+	err = encryptedTemplate.Execute(&result, encryptedContent{
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		IV:         base64.StdEncoding.EncodeToString(iv),
+		Payload:    base64.StdEncoding.EncodeToString(payload),
+		Iterations: encryptionIterations,
+	})
+	if err != nil {
+		return "", fmt.Errorf("[gen/parse/encrypt] unable to render encrypted template: %s", err)
+	}
+
+	return template.HTML(result.String()), nil
+}
+
+// loadEncryptedTemplate uses the configured encrypted template when present,
+// otherwise falling back to the built in unlock form.
+func loadEncryptedTemplate(path string) (*template.Template, error) {
+	if _, err := os.Stat(path); err == nil {
+		return parseTemplate(path)
+	}
+
+	return template.New("encrypted").Parse(defaultEncryptedTemplate)
+}
+
+// pbkdf2SHA256 implements PBKDF2 (RFC 8018) with HMAC-SHA256, matching the
+// WebCrypto derivation used to decrypt in the browser.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLength + prf.Size() - 1) / prf.Size()
+
+	key := make([]byte, 0, blocks*prf.Size())
+	counter := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		u := prf.Sum(nil)
+
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
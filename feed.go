@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description,omitempty"`
+}
+
+// feedInfo describes a feed, either the site wide one or a section's.
+type feedInfo struct {
+	Title       string
+	Description string
+
+	// Dir is the url of the directory the feed is written to and links to.
+	Dir string
+}
+
+func siteFeedInfo() feedInfo {
+	return feedInfo{Title: cfg.Title, Description: cfg.Description, Dir: "/"}
+}
+
+// feedPages selects the dated pages under dir listed in feeds, newest first.
+func feedPages(pages map[string]*page, dir string) []*page {
+	selected := make([]*page, 0)
+	for _, page := range pages {
+		if page.Type == "" || page.Date.IsZero() || page.Draft || page.Encrypted || !page.hasHTMLOutput() {
+			continue
+		}
+
+		url := pageURL(page.OutPath)
+		if matchesAny(cfg.FeedExclude, url) || !strings.HasPrefix(url, dir) {
+			continue
+		}
+
+		selected = append(selected, page)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].Date.Equal(selected[j].Date) {
+			return selected[i].OutPath < selected[j].OutPath
+		}
+		return selected[i].Date.After(selected[j].Date)
+	})
+
+	if cfg.FeedLimit > 0 && len(selected) > cfg.FeedLimit {
+		selected = selected[:cfg.FeedLimit]
+	}
+
+	return selected
+}
+
+func generateFeed(info feedInfo, pages []*page) rssFeed {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       info.Title,
+			Link:        baseURL + info.Dir,
+			Description: info.Description,
+			Items:       make([]rssItem, 0, len(pages)),
+		},
+	}
+
+	if len(pages) > 0 {
+		feed.Channel.LastBuildDate = pages[0].Date.Format(time.RFC1123Z)
+	}
+
+	for _, page := range pages {
+		link := baseURL + pageLink(page.OutPath)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       page.Title,
+			Link:        link,
+			GUID:        link,
+			PubDate:     page.Date.Format(time.RFC1123Z),
+			Description: string(page.Summary),
+		})
+	}
+
+	return feed
+}
+
+// writeFeeds writes a feed of pages in each of FeedFormats into info.Dir.
+func writeFeeds(info feedInfo, pages []*page) {
+	dir := filepath.Join("public", filepath.FromSlash(info.Dir))
+
+	for _, format := range cfg.FeedFormats {
+		switch strings.ToLower(format) {
+		case "rss":
+			writeXML(filepath.Join(dir, "feed.xml"), generateFeed(info, pages))
+		case "atom":
+			writeXML(filepath.Join(dir, "atom.xml"), generateAtomFeed(info, pages))
+		case "json":
+			writeJSON(filepath.Join(dir, "feed.json"), generateJSONFeed(info, pages))
+		default:
+			log.Printf("[gen/render/feed] unknown feed format %q, expected rss, atom or json", format)
+		}
+	}
+}
+
+func renderFeed(pages map[string]*page) {
+	writeFeeds(siteFeedInfo(), feedPages(pages, "/"))
+
+	if cfg.SectionFeeds {
+		for _, info := range sectionFeeds(pages) {
+			if selected := feedPages(pages, info.Dir); len(selected) > 0 {
+				writeFeeds(info, selected)
+			}
+		}
+	}
+}
+
+// sectionFeeds describes a feed for each top level section with dated pages,
+// titled after the section's index page when it has one. Language subtrees
+// aren't sections.
+func sectionFeeds(pages map[string]*page) []feedInfo {
+	sections := make(map[string]bool)
+	for _, page := range pages {
+		if page.Type == "" || page.Date.IsZero() {
+			continue
+		}
+
+		url := strings.TrimPrefix(pageURL(page.OutPath), "/")
+		if i := strings.Index(url, "/"); i > 0 && !isLanguage(url[:i]) {
+			sections[url[:i]] = true
+		}
+	}
+
+	feeds := make([]feedInfo, 0, len(sections))
+	for section := range sections {
+		info := feedInfo{
+			Title:       cfg.Title + " - " + displayName(section),
+			Description: cfg.Description,
+			Dir:         "/" + section + "/",
+		}
+
+		if index, ok := pages[filepath.ToSlash(filepath.Join("public", section, "index.html"))]; ok {
+			info.Title = index.Title
+			if index.Description != "" {
+				info.Description = index.Description
+			}
+		}
+
+		feeds = append(feeds, info)
+	}
+
+	sort.Slice(feeds, func(i, j int) bool {
+		return feeds[i].Dir < feeds[j].Dir
+	})
+
+	return feeds
+}
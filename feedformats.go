@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"strings"
+	"time"
+)
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title   string       `xml:"title"`
+	Link    atomLink     `xml:"link"`
+	ID      string       `xml:"id"`
+	Updated string       `xml:"updated"`
+	Summary *atomSummary `xml:"summary,omitempty"`
+}
+
+type atomSummary struct {
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// siteAuthor is the author of the site's Atom feeds, which require one,
+// falling back to the site's title when no Author is configured.
+func siteAuthor() atomAuthor {
+	if cfg.Author != "" {
+		return atomAuthor{Name: cfg.Author}
+	}
+
+	return atomAuthor{Name: cfg.Title}
+}
+
+// generateAtomFeed mirrors generateFeed as an Atom 1.0 feed.
+func generateAtomFeed(info feedInfo, pages []*page) atomFeed {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+
+	feed := atomFeed{
+		Title: info.Title,
+		Links: []atomLink{
+			{Href: baseURL + info.Dir},
+			{Href: baseURL + info.Dir + "atom.xml", Rel: "self"},
+		},
+		ID:      baseURL + info.Dir,
+		Updated: siteContext.BuildTime.In(siteLocation).Format(time.RFC3339),
+		Author:  siteAuthor(),
+		Entries: make([]atomEntry, 0, len(pages)),
+	}
+
+	if len(pages) > 0 {
+		feed.Updated = pages[0].Date.Format(time.RFC3339)
+	}
+
+	for _, page := range pages {
+		link := baseURL + pageLink(page.OutPath)
+		entry := atomEntry{
+			Title:   page.Title,
+			Link:    atomLink{Href: link},
+			ID:      link,
+			Updated: page.Date.Format(time.RFC3339),
+		}
+
+		if page.Summary != "" {
+			entry.Summary = &atomSummary{Type: "html", Content: string(page.Summary)}
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string `json:"id"`
+	URL           string `json:"url"`
+	Title         string `json:"title"`
+	ContentHTML   string `json:"content_html,omitempty"`
+	ContentText   string `json:"content_text,omitempty"`
+	Summary       string `json:"summary,omitempty"`
+	DatePublished string `json:"date_published"`
+}
+
+// generateJSONFeed mirrors generateFeed as a JSON Feed 1.1.
+func generateJSONFeed(info feedInfo, pages []*page) jsonFeed {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       info.Title,
+		HomePageURL: baseURL + info.Dir,
+		FeedURL:     baseURL + info.Dir + "feed.json",
+		Description: info.Description,
+		Items:       make([]jsonFeedItem, 0, len(pages)),
+	}
+
+	for _, page := range pages {
+		link := baseURL + pageLink(page.OutPath)
+		item := jsonFeedItem{
+			ID:            link,
+			URL:           link,
+			Title:         page.Title,
+			ContentHTML:   string(page.Summary),
+			Summary:       page.Description,
+			DatePublished: page.Date.Format(time.RFC3339),
+		}
+
+		// Items need content of one kind or the other.
+		if item.ContentHTML == "" {
+			item.ContentText = page.Description
+			if item.ContentText == "" {
+				item.ContentText = page.Title
+			}
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	return feed
+}
+
+func writeJSON(outPath string, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("[gen/render/json] unable to marshal %s: %s", outPath, err)
+		return
+	}
+
+	err = output.WriteFile(outPath, out)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", outPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", outPath)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestAtomFeedsHaveAnAuthor(t *testing.T) {
+	tests := []struct {
+		name   string
+		author string
+		want   string
+	}{
+		{"configured author", "Jane Doe", "<author><name>Jane Doe</name></author>"},
+		{"site title", "", "<author><name>Gen</name></author>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = defaultConfig()
+			cfg.Title = "Gen"
+			cfg.Author = tt.author
+
+			feeds := map[string]atomFeed{
+				"atom.xml":   generateAtomFeed(feedInfo{Title: "Gen", Dir: "/"}, nil),
+				"recent.xml": generateRecentFeed("/recent/", nil),
+			}
+			for name, feed := range feeds {
+				b, err := xml.Marshal(feed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(b), tt.want) {
+					t.Errorf("%s is %s, want it to contain %s", name, b, tt.want)
+				}
+			}
+		})
+	}
+}
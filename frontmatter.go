@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var frontMatterDelimiter = []byte("---")
+
+// parseFrontMatter splits a leading block of `key: value` lines delimited by
+// `---` from the source, returning the metadata and the remaining body.
+func parseFrontMatter(s []byte) (map[string]string, []byte) {
+	meta := make(map[string]string)
+
+	src := bytes.TrimPrefix(s, []byte("\ufeff"))
+	if !bytes.HasPrefix(src, frontMatterDelimiter) {
+		return meta, s
+	}
+
+	lines := bytes.SplitAfter(src, []byte("\n"))
+	if len(bytes.TrimSpace(lines[0])) != len(frontMatterDelimiter) {
+		return meta, s
+	}
+
+	offset := len(lines[0])
+	for _, line := range lines[1:] {
+		offset += len(line)
+		l := strings.TrimSpace(string(line))
+
+		if l == string(frontMatterDelimiter) {
+			return meta, src[offset:]
+		}
+
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+
+		key, value, found := strings.Cut(l, ":")
+		if !found {
+			continue
+		}
+
+		meta[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	// no closing delimiter, treat the whole file as content
+	return make(map[string]string), s
+}
+
+// parseList splits an inline front matter list, either `[a, b]` or `a, b`.
+func parseList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "["), "]")
+
+	list := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.Trim(strings.TrimSpace(item), `"'`)
+		if item != "" {
+			list = append(list, item)
+		}
+	}
+
+	return list
+}
+
+func parseBool(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+
+	return false
+}
+
+// siteLocation is the Timezone dates without a zone of their own are in,
+// and the zone dates are written out in.
+var siteLocation = time.UTC
+
+// loadLocation resolves the Timezone config, defaulting to UTC.
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/config] unknown timezone %q: %s", name, err)
+	}
+
+	return loc, nil
+}
+
+// parseDate tries each of the configured DateFormats in order, reading
+// dates without a zone in siteLocation and moving the rest into it.
+func parseDate(value string) (time.Time, error) {
+	for _, layout := range cfg.DateFormats {
+		t, err := time.ParseInLocation(layout, value, siteLocation)
+		if err == nil {
+			return t.In(siteLocation), nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("date %q doesn't match any of the formats %q", value, cfg.DateFormats)
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// templateFuncs are available to every html template.
+var templateFuncs = template.FuncMap{
+	"readFile":      readFile,
+	"highlightFile": highlightFile,
+	"safeHTML": func(s string) template.HTML {
+		return template.HTML(s)
+	},
+	"titleCase": titleCase,
+	"asset":     asset,
+	"include":   include,
+	"lqip":      lqip,
+}
+
+// parseTemplate parses a template file with templateFuncs registered.
+func parseTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
+
+// loadTemplate parses a required template, telling a missing file apart from
+// one that fails to parse. Parse errors carry the file and line at fault.
+func loadTemplate(name, path string) (*template.Template, error) {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("[gen/init/template] missing %s template, expected it at %s", name, path)
+	}
+
+	t, err := parseTemplate(path)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/template] unable to parse %s template %s: %s", name, path, err)
+	}
+
+	log.Printf("[gen/init/template] opened %s template", name)
+
+	return t, nil
+}
+
+// projectPath resolves a path relative to the project root, refusing any
+// that escape it. Symlinks are resolved in the root as well as the path, so
+// a project reached through a symlinked directory still contains its files.
+func projectPath(path string) (string, error) {
+	root, err := filepath.Abs(".")
+	if err != nil {
+		return "", err
+	}
+
+	root, err = filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+
+	abs, err := filepath.Abs(filepath.Join(root, filepath.FromSlash(path)))
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside the project directory", path)
+	}
+
+	return resolved, nil
+}
+
+// readFile returns the contents of a file relative to the project root.
+func readFile(path string) (string, error) {
+	resolved, err := projectPath(path)
+	if err != nil {
+		return "", fmt.Errorf("[gen/template/readFile] %s", err)
+	}
+
+	b, err := os.ReadFile(resolved)
+	if err != nil {
+		return "", fmt.Errorf("[gen/template/readFile] unable to read %s: %s", path, err)
+	}
+
+	return string(b), nil
+}
+
+// highlightFile returns a file as a code block tagged with its language by
+// rendering it as a fenced code block, so it goes through the same
+// conversion and highlighting as the code blocks in pages. The fence is
+// longer than any run of backticks in the file.
+func highlightFile(path string) (template.HTML, error) {
+	content, err := readFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	language := strings.TrimPrefix(filepath.Ext(path), ".")
+
+	fence := "```"
+	for strings.Contains(content, fence) {
+		fence += "`"
+	}
+
+	md := fmt.Sprintf("%s%s\n%s\n%s\n", fence, language, strings.TrimSuffix(content, "\n"), fence)
+	html, _ := cachedMarkdown2html([]byte(md), siteMarkdownOptions())
+
+	return html, nil
+}
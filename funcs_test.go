@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestProjectPath(t *testing.T) {
+	dir := t.TempDir()
+	project := filepath.Join(dir, "project")
+	link := filepath.Join(dir, "link")
+
+	for _, err := range []error{
+		os.Mkdir(project, 0700),
+		os.WriteFile(filepath.Join(project, "notes.txt"), nil, 0600),
+		os.WriteFile(filepath.Join(dir, "outside.txt"), nil, 0600),
+		os.Symlink(filepath.Join("..", "outside.txt"), filepath.Join(project, "escape.txt")),
+		os.Symlink("notes.txt", filepath.Join(project, "alias.txt")),
+		os.Symlink(project, link),
+	} {
+		if err != nil {
+			t.Skipf("unable to set up the project: %s", err)
+		}
+	}
+
+	// The project is entered through the symlink, as a shell cd'd into it
+	// would leave the working directory.
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PWD", link)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	resolvedProject, err := filepath.EvalSymlinks(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"file in the project", "notes.txt", "notes.txt"},
+		{"symlink within the project", "alias.txt", "notes.txt"},
+		{"parent directory", "../outside.txt", ""},
+		{"symlink out of the project", "escape.txt", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := projectPath(tt.path)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("projectPath(%q) = %q, want an error", tt.path, got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("projectPath(%q): %s", tt.path, err)
+			}
+			if want := filepath.Join(resolvedProject, tt.want); got != want {
+				t.Errorf("projectPath(%q) = %q, want %q", tt.path, got, want)
+			}
+		})
+	}
+}
+
+func TestHighlightFileMatchesFencedCode(t *testing.T) {
+	source := "// Fences look like ```go\nfunc main() { println(\"<tag>\") }\n"
+	newTestSite(t, map[string]string{
+		"code/main.go": source,
+	})
+
+	got, err := highlightFile("code/main.go")
+	if err != nil {
+		t.Fatalf("highlightFile: %s", err)
+	}
+
+	want, _ := markdown2html([]byte("````go\n"+source+"````\n"), siteMarkdownOptions())
+	if got != want {
+		t.Errorf("highlightFile rendered\n%s\nwant the fenced code block\n%s", got, want)
+	}
+
+	html := string(got)
+	if strings.Count(html, "<pre>") != 1 || !strings.Contains(html, `class="language-go"`) || !strings.Contains(html, "&lt;tag&gt;") {
+		t.Errorf("highlightFile didn't render one escaped go code block:\n%s", html)
+	}
+}
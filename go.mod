@@ -1,5 +1,10 @@
 module github.com/monoxane/gen
 
-go 1.19
+go 1.21
 
-require github.com/gomarkdown/markdown v0.0.0-20230322041520-c84983bdbf2a 
+require (
+	github.com/gomarkdown/markdown v0.0.0-20230322041520-c84983bdbf2a
+	golang.org/x/image v0.23.0
+	golang.org/x/net v0.33.0
+	golang.org/x/text v0.21.0
+)
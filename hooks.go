@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// runHook executes a configured shell command, streaming its output and
+// returning an error if it exits non-zero.
+func runHook(phase, command string) error {
+	log.Printf("[gen/hook/%s] running %s", phase, command)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Stdin = os.Stdin
+
+	err := cmd.Run()
+	if err != nil {
+		return fmt.Errorf("[gen/hook/%s] command failed: %s", phase, err)
+	}
+
+	log.Printf("[gen/hook/%s] completed %s", phase, command)
+
+	return nil
+}
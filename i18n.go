@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type translation struct {
+	Lang string
+	URL  string
+}
+
+func defaultLanguage() string {
+	if cfg.DefaultLanguage != "" {
+		return cfg.DefaultLanguage
+	} else if len(cfg.Languages) > 0 {
+		return cfg.Languages[0]
+	}
+
+	return ""
+}
+
+func isLanguage(lang string) bool {
+	for _, l := range cfg.Languages {
+		if strings.EqualFold(l, lang) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subtreeLanguage returns the language of a content path whose first
+// directory is named after a configured language, e.g. content/fr/about.md.
+func subtreeLanguage(path string) string {
+	segments := strings.Split(filepath.ToSlash(path), "/")
+	if len(segments) > 2 && isLanguage(segments[1]) {
+		return strings.ToLower(segments[1])
+	}
+
+	return ""
+}
+
+// localise detects the page language from a language suffixed filename
+// (about.fr.md) or a language subtree (content/fr/about.md), moving pages
+// that aren't in the default language under a /<lang>/ prefix.
+func (p *page) localise() {
+	if len(cfg.Languages) == 0 {
+		return
+	}
+
+	p.Lang = defaultLanguage()
+	p.translationKey = filepath.ToSlash(p.OutPath)
+
+	subtree := subtreeLanguage(p.Path)
+	if subtree != "" {
+		p.Lang = subtree
+		p.translationKey = "public" + strings.TrimPrefix(filepath.ToSlash(p.OutPath), "public/"+subtree)
+	}
+
+	suffix := filepath.Ext(p.Name)
+	if suffix == "" || !isLanguage(suffix[1:]) {
+		return
+	}
+
+	ext := filepath.Ext(p.OutPath)
+	p.Lang = strings.ToLower(suffix[1:])
+	p.Name = strings.TrimSuffix(p.Name, suffix)
+	p.OutPath = strings.TrimSuffix(p.OutPath, strings.ToLower(suffix)+ext) + ext
+	p.translationKey = strings.TrimSuffix(p.translationKey, strings.ToLower(suffix)+ext) + ext
+
+	if subtree == "" && p.Lang != defaultLanguage() {
+		p.OutPath = filepath.Join("public", p.Lang, strings.TrimPrefix(filepath.ToSlash(p.OutPath), "public/"))
+
+		err := output.MkdirAll(filepath.Dir(p.OutPath))
+		if err != nil {
+			log.Printf("[gen/process/dir] unable to create directory %s: %s", filepath.Dir(p.OutPath), err)
+		}
+	}
+}
+
+// linkTranslations populates each localised page with the other language
+// variants sharing its translation key, along with the hreflang alternate
+// tags linking the full set.
+func linkTranslations(pages map[string]*page) {
+	variants := make(map[string][]*page)
+	for _, page := range pages {
+		if page.Lang == "" {
+			continue
+		}
+
+		variants[page.translationKey] = append(variants[page.translationKey], page)
+	}
+
+	for _, group := range variants {
+		sort.Slice(group, func(i, j int) bool {
+			return group[i].Lang < group[j].Lang
+		})
+
+		hreflangTags := ""
+		if len(group) > 1 {
+			for _, variant := range group {
+				hreflangTags += hreflangTag(variant.Lang, variant.OutPath)
+				if variant.Lang == defaultLanguage() {
+					hreflangTags += hreflangTag("x-default", variant.OutPath)
+				}
+			}
+		}
+
+		for _, page := range group {
+			page.HreflangTags = template.HTML(hreflangTags)
+			page.Translations = make([]translation, 0, len(group)-1)
+			for _, variant := range group {
+				if variant == page {
+					continue
+				}
+
+				page.Translations = append(page.Translations, translation{
+					Lang: variant.Lang,
+					URL:  pageLink(variant.OutPath),
+				})
+			}
+		}
+	}
+}
+
+func hreflangTag(lang, outPath string) string {
+	url := strings.TrimSuffix(cfg.BaseURL, "/") + pageLink(outPath)
+	return fmt.Sprintf("<link rel=\"alternate\" hreflang=\"%s\" href=\"%s\">\n", template.HTMLEscapeString(lang), template.HTMLEscapeString(url))
+}
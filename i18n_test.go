@@ -0,0 +1,37 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestSubtreeLanguage(t *testing.T) {
+	cfg = defaultConfig()
+	cfg.Languages = []string{"en", "fr"}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		windows bool
+	}{
+		{"language subtree", filepath.Join("content", "fr", "about.md"), "fr", false},
+		{"unknown language", filepath.Join("content", "de", "about.md"), "", false},
+		{"language file at the root", filepath.Join("content", "fr.md"), "", false},
+		{"forward slashes", "content/fr/docs/intro.md", "fr", false},
+		{"backslashes", `content\fr\docs\intro.md`, "fr", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.windows && runtime.GOOS != "windows" {
+				t.Skip("backslashes only separate paths on windows")
+			}
+
+			if got := subtreeLanguage(tt.path); got != tt.want {
+				t.Errorf("subtreeLanguage(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
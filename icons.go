@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var iconTypes = map[string]string{
+	".ico":  "image/x-icon",
+	".png":  "image/png",
+	".svg":  "image/svg+xml",
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+}
+
+// iconTags builds the favicon, apple touch icon and theme colour tags for
+// every page's head from the config, available as .Site.IconTags
+func iconTags(c config) template.HTML {
+	var b strings.Builder
+
+	if c.Favicon != "" {
+		if t, ok := iconTypes[strings.ToLower(path.Ext(c.Favicon))]; ok {
+			fmt.Fprintf(&b, `<link rel="icon" type="%s" href="%s">`+"\n", t, html.EscapeString(c.Favicon))
+		} else {
+			fmt.Fprintf(&b, `<link rel="icon" href="%s">`+"\n", html.EscapeString(c.Favicon))
+		}
+	}
+
+	if c.AppleTouchIcon != "" {
+		fmt.Fprintf(&b, `<link rel="apple-touch-icon" href="%s">`+"\n", html.EscapeString(c.AppleTouchIcon))
+	}
+
+	if c.ThemeColor != "" {
+		fmt.Fprintf(&b, `<meta name="theme-color" content="%s">`+"\n", html.EscapeString(c.ThemeColor))
+	}
+
+	return template.HTML(b.String())
+}
+
+// checkIcons warns about configured icons that aren't in the output.
+func checkIcons(c config) {
+	for name, ref := range map[string]string{"favicon": c.Favicon, "apple touch icon": c.AppleTouchIcon} {
+		if !isLocalRef(ref) {
+			continue
+		}
+
+		target := resolveRef("/", ref)
+		if !output.Exists(filepath.Join("public", filepath.FromSlash(target))) {
+			log.Printf("[gen/validate/icons] %s %s doesn't exist in the output", name, ref)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+const includesDirectory = "includes"
+
+var errMissingInclude = errors.New("no include")
+
+var (
+	includeCache   = make(map[string]template.HTML)
+	includeCacheMu sync.Mutex
+)
+
+// includeUsers maps each include expanded into markdown sources to the
+// sources, and templateIncludes holds those templates call, so -watch knows
+// which pages a changed include affects.
+var (
+	includeUsers     = make(map[string]map[string]bool)
+	templateIncludes = make(map[string]bool)
+	includeUsersMu   sync.Mutex
+)
+
+// recordInclude notes that the markdown source expands include, or that a
+// template calls it when source is empty.
+func recordInclude(include, source string) {
+	include = filepath.Clean(include)
+
+	includeUsersMu.Lock()
+	defer includeUsersMu.Unlock()
+
+	if source == "" {
+		templateIncludes[include] = true
+		return
+	}
+
+	if includeUsers[include] == nil {
+		includeUsers[include] = make(map[string]bool)
+	}
+	includeUsers[include][source] = true
+}
+
+// includePath finds a snippet in the includes directory, or the theme's,
+// trying the markdown extensions then .html when name doesn't have one.
+func includePath(name string) (string, error) {
+	candidates := []string{name}
+	if filepath.Ext(name) == "" {
+		candidates = make([]string, 0, len(cfg.MarkdownExtensions)+1)
+		for _, ext := range append(append([]string(nil), cfg.MarkdownExtensions...), ".html") {
+			candidates = append(candidates, name+ext)
+		}
+	}
+
+	for _, candidate := range candidates {
+		path := themePath(filepath.Join(includesDirectory, filepath.FromSlash(candidate)))
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("%w named %s in %s", errMissingInclude, name, includesDirectory)
+}
+
+// include returns a snippet from the includes directory for templates,
+// rendering it first when it's markdown.
+func include(name string) (template.HTML, error) {
+	includeCacheMu.Lock()
+	defer includeCacheMu.Unlock()
+
+	if html, ok := includeCache[name]; ok {
+		return html, nil
+	}
+
+	path, err := includePath(name)
+	if err != nil {
+		return "", fmt.Errorf("[gen/template/include] %s", err)
+	}
+	recordInclude(path, "")
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("[gen/template/include] unable to read %s: %s", path, err)
+	}
+
+	html := template.HTML(b)
+	if isMarkdown(path) {
+		html, _ = cachedMarkdown2html(b, siteMarkdownOptions())
+	}
+
+	includeCache[name] = html
+	return html, nil
+}
+
+// includeActions holds the include action regexps by delimiter pair, looked
+// up while pages parse concurrently.
+var (
+	includeActions   = make(map[[2]string]*regexp.Regexp)
+	includeActionsMu sync.Mutex
+)
+
+// includeActionRegexp matches an include action between the delimiters,
+// capturing the name of the snippet.
+func includeActionRegexp(left, right string) *regexp.Regexp {
+	includeActionsMu.Lock()
+	defer includeActionsMu.Unlock()
+
+	delims := [2]string{left, right}
+	if re, ok := includeActions[delims]; ok {
+		return re
+	}
+
+	re := regexp.MustCompile(regexp.QuoteMeta(left) + `\s*include\s+"([^"]+)"\s*` + regexp.QuoteMeta(right))
+	includeActions[delims] = re
+
+	return re
+}
+
+// expandIncludes replaces include actions in a markdown source, like
+// {{include "notice"}}, with the source of the snippet so it renders as part
+// of the page. Markdown pages aren't executed as templates so the include
+// func can't be used in them directly.
+func expandIncludes(path string, md []byte) []byte {
+	re := includeActionRegexp(cfg.LeftDelim, cfg.RightDelim)
+
+	return re.ReplaceAllFunc(md, func(action []byte) []byte {
+		name := string(re.FindSubmatch(action)[1])
+
+		includePath, err := includePath(name)
+		if err == nil {
+			recordInclude(includePath, path)
+
+			var b []byte
+			b, err = os.ReadFile(includePath)
+			if err == nil {
+				return b
+			}
+		}
+
+		if errors.Is(err, errMissingInclude) {
+			if reportMissing("parse/include", path, "missing include %s", name) {
+				return nil
+			}
+			return action
+		}
+
+		report.Add("parse/include", path, "unable to include %s: %s", name, err)
+		return action
+	})
+}
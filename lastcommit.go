@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commitInfo is the last commit touching a page's source, for showing who
+// last edited it and when.
+type commitInfo struct {
+	Author string
+	Date   time.Time
+	Hash   string
+}
+
+// lastCommits maps sources, by their slash separated path in the project,
+// to the last commit touching them, loaded once a build by loadLastCommits.
+var lastCommits map[string]*commitInfo
+
+// loadLastCommits reads the whole history of the project in one git log,
+// keeping the newest commit of every file, so pages don't spawn git each.
+// It's best effort, projects outside a repository or without git get no
+// commits and pages are built without them.
+func loadLastCommits() map[string]*commitInfo {
+	commits := make(map[string]*commitInfo)
+
+	out, err := exec.Command("git", "-c", "core.quotePath=false", "log", "--relative", "--name-only", "--no-renames", "--format=%x01%h%x09%an%x09%cI").Output()
+	if err != nil {
+		log.Printf("[gen/init/git] unable to read git history, pages won't have commits: %s", err)
+		return commits
+	}
+
+	var current *commitInfo
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "\x01") {
+			current = nil
+
+			fields := strings.SplitN(strings.TrimPrefix(line, "\x01"), "\t", 3)
+			if len(fields) != 3 {
+				continue
+			}
+
+			date, err := time.Parse(time.RFC3339, fields[2])
+			if err != nil {
+				continue
+			}
+
+			current = &commitInfo{Hash: fields[0], Author: fields[1], Date: date.In(siteLocation)}
+			continue
+		}
+
+		if line == "" || current == nil {
+			continue
+		}
+		if _, ok := commits[line]; !ok {
+			commits[line] = current
+		}
+	}
+
+	return commits
+}
+
+// lastCommit returns the last commit touching path, nil when git doesn't
+// know the file.
+func lastCommit(path string) *commitInfo {
+	return lastCommits[filepath.ToSlash(filepath.Clean(path))]
+}
+
+// setLastCommit exposes the last commit of the page's source as LastCommit,
+// with the LastCommit option.
+func (p *page) setLastCommit() {
+	if !cfg.LastCommit || cfg.ContentArchive != "" {
+		return
+	}
+
+	p.LastCommit = lastCommit(p.Path)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"sync"
+)
+
+var (
+	layoutTemplates   = make(map[string]*template.Template)
+	layoutImports     = make(map[string]template.HTML)
+	layoutTemplatesMu sync.Mutex
+)
+
+// layoutTemplate returns the template for a markdown page layout, loaded from
+// layout-<name>.html in TemplateDir and falling back to the markdown template
+// when the layout doesn't have one of its own.
+func layoutTemplate(layout string) *template.Template {
+	if layout == "" {
+		return mdTemplate
+	}
+
+	layoutTemplatesMu.Lock()
+	defer layoutTemplatesMu.Unlock()
+
+	if t, ok := layoutTemplates[layout]; ok {
+		return t
+	}
+
+	t := mdTemplate
+	path := templatePath("layout-" + layout + ".html")
+	if _, err := os.Stat(path); err == nil {
+		parsed, err := parseTemplate(path)
+		if err != nil {
+			log.Printf("[gen/init/template] unable to parse %s layout template, using markdown template: %s", layout, err)
+		} else {
+			log.Printf("[gen/init/template] opened %s layout template", layout)
+			t = parsed
+		}
+	}
+
+	layoutTemplates[layout] = t
+	return t
+}
+
+// layoutStaticImports returns the static imports partial for a layout from
+// static-<name>.html in TemplateDir, falling back to static.html.
+func layoutStaticImports(layout string) (template.HTML, error) {
+	layoutTemplatesMu.Lock()
+	defer layoutTemplatesMu.Unlock()
+
+	if imports, ok := layoutImports[layout]; ok {
+		return imports, nil
+	}
+
+	path := templatePath("static.html")
+	if layout != "" {
+		layoutPath := templatePath("static-" + layout + ".html")
+		if _, err := os.Stat(layoutPath); !errors.Is(err, fs.ErrNotExist) {
+			path = layoutPath
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	layoutImports[layout] = template.HTML(b)
+	return layoutImports[layout], nil
+}
+
+// hasFooter reports whether the footer is rendered into the page, unless its
+// footer front matter is false or its layout is in FooterlessLayouts, where
+// footer: true brings it back.
+func (p *page) hasFooter() bool {
+	if footer, ok := p.Meta["footer"]; ok {
+		return parseBool(footer)
+	}
+
+	for _, layout := range cfg.FooterlessLayouts {
+		if layout == p.Layout {
+			return false
+		}
+	}
+
+	return true
+}
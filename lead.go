@@ -0,0 +1,75 @@
+package main
+
+import (
+	"html/template"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// setLead marks the first paragraph of the page's content, skipping those
+// nested in lists, quotes and other blocks, as its lead with class="lead"
+// and exposes its inner html as Lead, for styling a standfirst. It runs when
+// Lead is set or the page's lead front matter is true. The rendered content
+// is parsed rather than the whole page, so paragraphs in the template's own
+// chrome aren't picked, and .Lead is known before the template runs. Only
+// markdown pages, including those from Processors, have a lead, html sources
+// are templates that parsing and rendering as html would garble.
+func (p *page) setLead() {
+	enabled := cfg.Lead
+	if lead, ok := p.Meta["lead"]; ok {
+		enabled = parseBool(lead)
+	}
+
+	if !enabled || p.Type != "MD" || p.Encrypted {
+		return
+	}
+
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := html.ParseFragment(strings.NewReader(string(p.Content)), body)
+	if err != nil {
+		report.Add("parse/lead", p.Path, "unable to parse content: %s", err)
+		return
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+
+	var lead *html.Node
+	for n := body.FirstChild; n != nil && lead == nil; n = n.NextSibling {
+		if n.DataAtom == atom.P {
+			lead = n
+		}
+	}
+	if lead == nil {
+		return
+	}
+
+	class := "lead"
+	if existing := getAttr(lead, "class"); existing != "" {
+		class = existing + " lead"
+	}
+	setAttr(lead, "class", class)
+
+	var inner strings.Builder
+	for n := lead.FirstChild; n != nil; n = n.NextSibling {
+		err = html.Render(&inner, n)
+		if err != nil {
+			report.Add("parse/lead", p.Path, "unable to render lead: %s", err)
+			return
+		}
+	}
+
+	var content strings.Builder
+	for n := body.FirstChild; n != nil; n = n.NextSibling {
+		err = html.Render(&content, n)
+		if err != nil {
+			report.Add("parse/lead", p.Path, "unable to render content: %s", err)
+			return
+		}
+	}
+
+	p.Lead = template.HTML(inner.String())
+	p.Content = template.HTML(content.String())
+}
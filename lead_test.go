@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLeadLeavesHTMLSourcesAlone(t *testing.T) {
+	about := `<html><head><title>{{.Title}}</title></head><body><p><a href="{{if eq .Title "x"}}/a{{end}}">About</a></p></body></html>`
+	out := newTestSite(t, map[string]string{
+		"content/about.html": about,
+		"content/post.md":    "# Post\n\nThe standfirst.\n\nThe rest.\n",
+	})
+	cfg.Lead = true
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	b, err := out.ReadFile("public/about.html")
+	if err != nil {
+		t.Fatalf("about.html wasn't written: %s", err)
+	}
+	if !strings.HasPrefix(string(b), "<html><head><title>about</title>") || strings.Contains(string(b), "lead") {
+		t.Errorf("about.html was changed by the lead:\n%s", b)
+	}
+
+	b, err = out.ReadFile("public/post.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `<p class="lead">The standfirst.</p>`) {
+		t.Errorf("post.html has no lead:\n%s", b)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// parseOnly parses the site into pages without rendering, writing anything
+// to public.
+func parseOnly() error {
+	err := prepareBuild(newMemoryOutput())
+	if err != nil {
+		return err
+	}
+
+	_, err = parseSite()
+	return err
+}
+
+type pageListing struct {
+	Source    string
+	Output    string
+	Type      string
+	Title     string
+	Date      *time.Time `json:",omitempty"`
+	Draft     bool
+	Backlinks int
+}
+
+// listPages parses the site, without rendering or writing anything, and
+// prints every page found as a table, or as json.
+func listPages(w io.Writer, asJSON bool) error {
+	err := parseOnly()
+	if err != nil {
+		return err
+	}
+
+	listings := make([]pageListing, 0, len(pages))
+	for _, p := range pages {
+		if p.Type == "" {
+			continue
+		}
+
+		listing := pageListing{
+			Source:    p.Path,
+			Output:    p.OutPath,
+			Type:      p.Type,
+			Title:     p.Title,
+			Draft:     p.Draft,
+			Backlinks: len(p.Backlinks),
+		}
+		if !p.Date.IsZero() {
+			date := p.Date
+			listing.Date = &date
+		}
+
+		listings = append(listings, listing)
+	}
+
+	sort.Slice(listings, func(i, j int) bool {
+		return listings[i].Source < listings[j].Source
+	})
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(listings)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tOUTPUT\tTYPE\tTITLE\tDATE\tDRAFT\tBACKLINKS")
+	for _, l := range listings {
+		date := ""
+		if l.Date != nil {
+			date = l.Date.Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\t%d\n", l.Source, l.Output, l.Type, l.Title, date, l.Draft, l.Backlinks)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// llmsPages selects the published html pages listed in llms.txt, in url order.
+func llmsPages(pages map[string]*page) []*page {
+	selected := make([]*page, 0)
+	for _, page := range pages {
+		if page.Type == "" || page.Draft || page.Encrypted || !page.hasHTMLOutput() {
+			continue
+		}
+
+		selected = append(selected, page)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return pageURL(selected[i].OutPath) < pageURL(selected[j].OutPath)
+	})
+
+	return selected
+}
+
+// generateLLMsTxt writes llms.txt listing every page with its url and
+// description and, when enabled, llms-full.txt with the plain text of each
+// page.
+func generateLLMsTxt(pages map[string]*page, c config) {
+	baseURL := strings.TrimSuffix(c.BaseURL, "/")
+	selected := llmsPages(pages)
+
+	var index strings.Builder
+	index.WriteString("# " + c.Title + "\n\n")
+	if c.Description != "" {
+		index.WriteString("> " + c.Description + "\n\n")
+	}
+	index.WriteString("## Pages\n\n")
+
+	var full strings.Builder
+	full.WriteString("# " + c.Title + "\n\n")
+
+	// html sources are templates, their actions are dropped rather than
+	// listed as text
+	reAction := regexp.MustCompile(`(?s)` + regexp.QuoteMeta(cfg.LeftDelim) + `.*?` + regexp.QuoteMeta(cfg.RightDelim))
+
+	for _, page := range selected {
+		url := baseURL + pageLink(page.OutPath)
+
+		index.WriteString("- [" + page.Title + "](" + url + ")")
+		if page.Description != "" {
+			index.WriteString(": " + page.Description)
+		}
+		index.WriteString("\n")
+
+		if c.LLMsFullTxt {
+			full.WriteString("## " + page.Title + "\n\n")
+			full.WriteString("Source: " + url + "\n\n")
+			content := page.Content
+			if page.Type == "HTML" {
+				content = template.HTML(reAction.ReplaceAllString(string(content), ""))
+			}
+			full.WriteString(plainText(content) + "\n\n")
+		}
+	}
+
+	writeText(filepath.Join("public", "llms.txt"), index.String())
+
+	if c.LLMsFullTxt {
+		writeText(filepath.Join("public", "llms-full.txt"), full.String())
+	}
+}
+
+func writeText(outPath, content string) {
+	err := output.WriteFile(outPath, []byte(content))
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", outPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", outPath)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const lockPath = ".gen.lock"
+
+// acquireLock creates the build lock holding this process's pid, failing when
+// another build holds it unless force takes over a stale lock. The lock is
+// released on interrupt so a cancelled build doesn't leave it behind.
+func acquireLock(force bool) error {
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if errors.Is(err, fs.ErrExist) && force {
+		log.Printf("[gen/lock] taking over the lock held by pid %s under -force", lockHolder())
+		err = os.Remove(lockPath)
+		if err == nil {
+			f, err = os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		}
+	}
+
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("[gen/lock] another build (pid %s) holds %s, run with -force if it's stale", lockHolder(), lockPath)
+	}
+	if err != nil {
+		return fmt.Errorf("[gen/lock] unable to create %s: %s", lockPath, err)
+	}
+
+	_, err = f.WriteString(strconv.Itoa(os.Getpid()))
+	f.Close()
+	if err != nil {
+		os.Remove(lockPath)
+		return fmt.Errorf("[gen/lock] unable to write %s: %s", lockPath, err)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		releaseLock()
+		os.Exit(130)
+	}()
+
+	return nil
+}
+
+func lockHolder() string {
+	b, err := os.ReadFile(lockPath)
+	if err != nil || len(b) == 0 {
+		return "unknown"
+	}
+
+	return strings.TrimSpace(string(b))
+}
+
+func releaseLock() {
+	err := os.Remove(lockPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[gen/lock] unable to remove %s: %s", lockPath, err)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// jsonLogs is set by -log-format json, switching every log line to a json
+// object on stderr with the phase, path and duration as fields.
+var jsonLogs bool
+
+// setupLogging selects the log format. The human format is the standard
+// log package output with its [gen/phase] prefixes.
+func setupLogging(format string) error {
+	switch format {
+	case "", "human", "text":
+		jsonLogs = false
+	case "json":
+		jsonLogs = true
+		slog.SetDefault(slog.New(phaseHandler{slog.NewJSONHandler(os.Stderr, nil)}))
+	default:
+		return fmt.Errorf("[gen/init/log] unknown log format %q, expected human or json", format)
+	}
+
+	return nil
+}
+
+// phaseHandler lifts the [gen/phase] prefix of messages logged through the
+// log package into a phase field.
+type phaseHandler struct {
+	slog.Handler
+}
+
+func (h phaseHandler) Handle(ctx context.Context, r slog.Record) error {
+	if strings.HasPrefix(r.Message, "[gen/") {
+		if end := strings.Index(r.Message, "] "); end > 0 {
+			phase := r.Message[len("[gen/"):end]
+			record := slog.NewRecord(r.Time, r.Level, r.Message[end+2:], r.PC)
+			record.AddAttrs(slog.String("phase", phase))
+			r.Attrs(func(a slog.Attr) bool {
+				record.AddAttrs(a)
+				return true
+			})
+			r = record
+		}
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h phaseHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return phaseHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h phaseHandler) WithGroup(name string) slog.Handler {
+	return phaseHandler{h.Handler.WithGroup(name)}
+}
+
+// logProblem logs a reported problem, as a warning with its path as a field
+// in json logs.
+func logProblem(p problem) {
+	if !jsonLogs {
+		log.Print(p)
+		return
+	}
+
+	slog.Warn(p.Message, "phase", p.Phase, "path", p.Path)
+}
+
+// logDuration logs how long a phase took since start.
+func logDuration(phase, message string, start time.Time) {
+	duration := time.Since(start)
+	if !jsonLogs {
+		log.Printf("[gen/%s] %s in %s", phase, message, duration.Round(time.Millisecond))
+		return
+	}
+
+	slog.Info(message, "phase", phase, "duration", duration)
+}
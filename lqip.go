@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	lqipCacheDir = ".cache/lqip"
+	lqipWidth    = 16
+)
+
+// lqipCache holds the placeholder of each image by a hash of its content,
+// generated once however many pages ask for it at the same time.
+var (
+	lqipCache   = make(map[string]*lqipEntry)
+	lqipCacheMu sync.Mutex
+)
+
+type lqipEntry struct {
+	once sync.Once
+	uri  string
+	err  error
+}
+
+func init() {
+	registerTransform("lqip", func() bool { return cfg.LQIP }, addPlaceholders)
+}
+
+// addPlaceholders gives every local image a tiny blurred copy of itself as a
+// background, shown until the image loads.
+func addPlaceholders(doc *html.Node, p *page) error {
+	url := pageURL(p.OutPath)
+	walkElements(doc, func(n *html.Node) {
+		if n.DataAtom != atom.Img {
+			return
+		}
+
+		src := getAttr(n, "src")
+		if !isLocalRef(src) {
+			return
+		}
+
+		placeholder, err := imagePlaceholder(resolveRef(url, src))
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, image.ErrFormat) {
+			// Missing images are reported by the asset lint, and formats the
+			// standard library can't decode, like svg, go without.
+			return
+		}
+		if err != nil {
+			log.Printf("[gen/render/lqip] no placeholder for %s in %s: %s", src, p.Path, err)
+			return
+		}
+
+		style := fmt.Sprintf("background-image:url(%s);background-size:cover", placeholder)
+		for i, attr := range n.Attr {
+			if attr.Key == "style" {
+				n.Attr[i].Val = strings.TrimSuffix(attr.Val, ";") + ";" + style
+				return
+			}
+		}
+		n.Attr = append(n.Attr, html.Attribute{Key: "style", Val: style})
+	})
+
+	return nil
+}
+
+// lqip returns the placeholder data uri of a site image for templates, e.g.
+// {{lqip "/img/hero.jpg"}}
+func lqip(src string) (template.URL, error) {
+	uri, err := imagePlaceholder(resolveRef("/", src))
+	if err != nil {
+		return "", fmt.Errorf("[gen/template/lqip] %s", err)
+	}
+
+	return template.URL(uri), nil
+}
+
+// imagePlaceholder returns a data uri of the image at the site relative url
+// scaled down to a few pixels wide, cached on disk by a hash of the image so
+// it's only generated once. The browser's upscaling blurs it. Images held
+// back by ConditionalAssets are read from their source, since they're only
+// copied once every page has rendered.
+func imagePlaceholder(url string) (string, error) {
+	b, err := output.ReadFile(filepath.Join("public", filepath.FromSlash(url)))
+	if errors.Is(err, fs.ErrNotExist) {
+		conditionalAssetsMu.Lock()
+		source, held := conditionalAssets[url]
+		conditionalAssetsMu.Unlock()
+
+		if held {
+			b, err = readSource(source)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(b)
+	key := hex.EncodeToString(sum[:])
+
+	// The lock only covers the map, pages showing the same image wait on
+	// the one generating it and other images are generated alongside.
+	lqipCacheMu.Lock()
+	entry, ok := lqipCache[key]
+	if !ok {
+		entry = &lqipEntry{}
+		lqipCache[key] = entry
+	}
+	lqipCacheMu.Unlock()
+
+	entry.once.Do(func() {
+		entry.uri, entry.err = generatePlaceholder(key, b)
+	})
+
+	return entry.uri, entry.err
+}
+
+// generatePlaceholder reads the placeholder of the image b hashing to key
+// from the disk cache, or generates and stores it.
+func generatePlaceholder(key string, b []byte) (string, error) {
+	cachePath := filepath.Join(lqipCacheDir, key+".txt")
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return string(cached), nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+
+	var encoded bytes.Buffer
+	err = png.Encode(&encoded, downscale(img, lqipWidth))
+	if err != nil {
+		return "", err
+	}
+
+	uri := "data:image/png;base64," + base64.StdEncoding.EncodeToString(encoded.Bytes())
+
+	err = writeCacheEntry(lqipCacheDir, cachePath, []byte(uri))
+	if err != nil {
+		log.Printf("[gen/cache/lqip] unable to write cache entry %s: %s", cachePath, err)
+	}
+
+	return uri, nil
+}
+
+// downscale shrinks an image to width pixels wide, averaging the pixels
+// each output pixel covers.
+func downscale(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() < width {
+		width = bounds.Dx()
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := bounds.Min.Y+y*bounds.Dy()/height, bounds.Min.Y+(y+1)*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			x0, x1 := bounds.Min.X+x*bounds.Dx()/width, bounds.Min.X+(x+1)*bounds.Dx()/width
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBAModel.Convert(img.At(sx, sy)).(color.NRGBA)
+					r, g, b, a = r+uint64(c.R), g+uint64(c.G), b+uint64(c.B), a+uint64(c.A)
+					n++
+				}
+			}
+
+			if n > 0 {
+				small.SetNRGBA(x, y, color.NRGBA{R: uint8(r / n), G: uint8(g / n), B: uint8(b / n), A: uint8(a / n)})
+			}
+		}
+	}
+
+	return small
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testPNG encodes a small solid image.
+func testPNG(t *testing.T, c color.Color) string {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, 32, 16))
+	for x := 0; x < 32; x++ {
+		for y := 0; y < 16; y++ {
+			img.Set(x, y, c)
+		}
+	}
+
+	var b bytes.Buffer
+	err := png.Encode(&b, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b.String()
+}
+
+func TestPlaceholderForConditionalAsset(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"content/page.md":      "# Page\n\n![Hero](/img/hero.png)\n",
+		"content/img/hero.png": testPNG(t, color.RGBA{R: 200, A: 255}),
+	})
+	cfg.LQIP = true
+	cfg.ConditionalAssets = []string{"/img/*"}
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	b, err := out.ReadFile("public/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "background-image:url(data:image/png;base64,") {
+		t.Errorf("the held back image has no placeholder:\n%s", b)
+	}
+	if !out.Exists("public/img/hero.png") {
+		t.Errorf("the referenced image wasn't copied")
+	}
+}
+
+func TestPlaceholderConcurrently(t *testing.T) {
+	out := newTestSite(t, map[string]string{})
+	for _, name := range []string{"a", "b"} {
+		err := out.WriteFile("public/img/"+name+".png", []byte(testPNG(t, color.RGBA{G: 100, A: 255})))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	uris := make([]string, 16)
+	errs := make([]error, len(uris))
+	for i := range uris {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			uris[i], errs[i] = imagePlaceholder([]string{"/img/a.png", "/img/b.png"}[i%2])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, uri := range uris {
+		if errs[i] != nil {
+			t.Fatalf("imagePlaceholder: %s", errs[i])
+		}
+		if uri != uris[0] {
+			t.Errorf("placeholders of the same image differ: %q and %q", uri, uris[0])
+		}
+	}
+}
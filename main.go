@@ -2,56 +2,136 @@ package main
 
 import (
 	"bytes"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
 	"io/fs"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
 
 	"github.com/gomarkdown/markdown"
+	"github.com/gomarkdown/markdown/ast"
 	"github.com/gomarkdown/markdown/html"
 	"github.com/gomarkdown/markdown/parser"
 )
 
 type page struct {
 	Path          string
+	SourcePath    string
+	EditURL       string
 	OutPath       string
 	Name          string
+	Title         string
+	Date          time.Time
+	Meta          map[string]string
 	Type          string
-	Backlinks     map[string]string
+	Layout        string
+	Outputs       []string
+	Encrypted     bool
+	Draft         bool
+	Hidden        bool
+	NoIndex       bool
+	RobotsMeta    template.HTML
+	Roles         []string
+	Weight        int
+	Lang          string
+	Translations  []translation
+	HreflangTags  template.HTML
+	Backlinks     map[string]backlink
 	InternalLinks map[string]string
 	ExternalLinks map[string]string
+	Summary       template.HTML
+	Lead          template.HTML
+	Description   string
 	Content       template.HTML
+	Sections      []pageSection
+	Children      []*page
+	ModTime       time.Time
+	LastCommit    *commitInfo
+	Attachments   []attachment
+	Context       map[string]interface{}
+	CriticalCSS   template.HTML
+	AMPURL        string
+	OGImage       string
 	Navigation    template.HTML
 	Footer        template.HTML
 	StaticImports template.HTML
+	Site          *site
+	Archive       []archiveYear
+	Recent        []archiveEntry
+
+	translationKey  string
+	markdownOptions markdownOptions
+	extraImports    template.HTML
 }
 
 func (p *page) Render() {
-	var result bytes.Buffer
-	footerTemplate.Execute(&result, p)
+	p.Footer = ""
+	if p.hasFooter() {
+		var result bytes.Buffer
+		footerTemplate.Execute(&result, p)
+
+		p.Footer = template.HTML(result.String())
+	}
+
+	if p.hasHTMLOutput() {
+		switch p.Type {
+		case "HTML":
+			renderHtml(*p)
+		case "MD":
+			renderMd(*p)
+		}
+	}
 
-	p.Footer = template.HTML(result.String())
+	for _, format := range p.Outputs {
+		if format != "html" {
+			renderOutput(*p, format)
+		}
+	}
 
-	switch p.Type {
-	case "HTML":
-		renderHtml(*p)
-	case "MD":
-		renderMd(*p)
+	if p.AMPURL != "" {
+		renderAMP(*p)
 	}
 }
 
 var (
-	mdTemplate      *template.Template
-	footerTemplate  *template.Template
-	sitemapTemplate *template.Template
-	reHref          regexp.Regexp
-	reExtHref       regexp.Regexp
-	pages           map[string]*page = make(map[string]*page)
+	mdTemplate        *template.Template
+	footerTemplate    *template.Template
+	sitemapTemplate   *template.Template
+	archiveTemplate   *template.Template
+	editURLTemplate   *texttemplate.Template
+	encryptedTemplate *template.Template
+	reHref            regexp.Regexp
+	reExtHref         regexp.Regexp
+	pages             map[string]*page = make(map[string]*page)
+
+	flagStrict         = flag.Bool("strict", false, "fail the build when any problems are reported")
+	flagStrictMarkdown = flag.Bool("strict-markdown", false, "fail the build on markdown warnings")
+	flagDrafts         = flag.Bool("drafts", false, "include draft pages and _drafts directories")
+	flagPreview        = flag.Bool("preview", false, "build the site with drafts into preview instead of public")
+	flagLogFormat      = flag.String("log-format", "human", "log format, human or json")
+	flagForce          = flag.Bool("force", false, "take over the build lock left by a build that didn't finish")
+	flagTags           = flag.String("tags", "", "comma separated build tags, pages with buildTags in their front matter are only built when one matches")
+	flagStats          = flag.Bool("stats", false, "print content statistics without building the site")
+	flagList           = flag.Bool("list", false, "list the pages found and their metadata without building the site")
+	flagJSON           = flag.Bool("json", false, "print -list and -stats as json")
+	flagRender         = flag.String("render", "", "render a single content file to stdout without building the site")
+	flagBook           = flag.String("book", "", "write the pages of a section, like /docs/, as one printable page to BookPath without building the site")
+	flagServe          = flag.String("serve", "", "serve the built site on this address, like :8080, with /_gen/page?path= rendering single sources for editor previews")
+	flagValidate       = flag.Bool("validate", false, "parse, render and check the whole site in memory, writing nothing and failing on any problem")
+	flagNew            = flag.String("new", "", "create a markdown page in content, like post/my-title, from its section's archetype in archetypes")
+	flagWatch          = flag.Bool("watch", false, "after building, re-render the pages using a template or include whenever it changes, alongside -serve or on its own")
+	flagCheck          = flag.Bool("check", false, "build the site in memory and fail if the committed public directory differs from it, listing the files")
+	flagProject        = flag.String("project", "", "project directory with gen.json, content and templates, defaults to the working directory")
 )
 
 func NewPage(path, outPath, name string) (page, error) {
@@ -59,67 +139,356 @@ func NewPage(path, outPath, name string) (page, error) {
 		Path:      path,
 		OutPath:   outPath,
 		Name:      name,
-		Backlinks: make(map[string]string, 0),
+		Title:     name,
+		Meta:      make(map[string]string),
+		Site:      siteContext,
+		Backlinks: make(map[string]backlink, 0),
 	}
 
-	navigationPartial, err := os.ReadFile("template/navigation.html")
+	navigationPartial, err := os.ReadFile(templatePath("navigation.html"))
 	if err != nil {
 		return page{}, fmt.Errorf("[gen/page/new] unable to open navigation partial: %s", err)
 	}
 	p.Navigation = template.HTML(navigationPartial)
+	p.CriticalCSS = criticalCSS
 
-	// footerPartial, err := os.ReadFile("template/footer.html")
+	// footerPartial, err := os.ReadFile(templatePath("footer.html"))
 	// if err != nil {
 	// 	return page{}, fmt.Errorf("[gen/page/new] unable to open footer partial: %s", err)
 	// }
 	// p.Footer = template.HTML(footerPartial)
 
-	staticImportPatials, err := os.ReadFile("template/static.html")
+	p.StaticImports, err = layoutStaticImports("")
 	if err != nil {
 		return page{}, fmt.Errorf("[gen/page/new] unable to open static imports partial: %s", err)
 	}
-	p.StaticImports = template.HTML(staticImportPatials)
 
 	return p, nil
 }
 
 func main() {
+	flag.Parse()
+
+	err := setupLogging(*flagLogFormat)
+	if err != nil {
+		log.Print(err)
+		os.Exit(2)
+	}
+
+	err = enterProject(*flagProject)
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	cfg, err = loadConfig("gen.json")
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	if *flagNew != "" {
+		err = newContent(*flagNew)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagList {
+		err = listPages(os.Stdout, *flagJSON)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagStats {
+		err = printStats(os.Stdout, *flagJSON)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagValidate {
+		os.Exit(validateSite())
+	}
+
+	if *flagCheck {
+		os.Exit(checkOutput())
+	}
+
+	if *flagBook != "" {
+		err = writeBook(*flagBook)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *flagRender != "" {
+		err = renderSingle(*flagRender, os.Stdout)
+		if err != nil {
+			log.Print(err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	err = acquireLock(*flagForce)
+	if err != nil {
+		log.Print(err)
+		os.Exit(1)
+	}
+
+	os.Exit(buildAndServe())
+}
+
+// buildAndServe builds the site, then serves or watches it when asked,
+// returning the exit code. It holds the build lock taken by main and
+// releases it however the build ends.
+func buildAndServe() int {
+	defer releaseLock()
+	defer closeContent()
+
+	code := buildSite()
+	if code == 0 && *flagWatch {
+		if *flagServe == "" {
+			watchTemplates(output)
+		} else {
+			go watchTemplates(output)
+		}
+	}
+	if code == 0 && *flagServe != "" {
+		err := serveSite(*flagServe)
+		if err != nil {
+			log.Printf("[gen/serve] %s", err)
+			code = 1
+		}
+	}
+
+	return code
+}
+
+// buildSite runs the hooks around a full build, returning the exit code.
+func buildSite() int {
+	var err error
+	if cfg.PreBuild != "" {
+		err = runHook("prebuild", cfg.PreBuild)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	out := newDiskOutput()
+
+	var hashed *hashingOutput
+	if *flagPreview {
+		*flagDrafts = true
+		out = rootedOutput{out: out, root: previewDirectory}
+		log.Printf("[gen/preview] building with drafts into %s", previewDirectory)
+	} else if cfg.ChangesFile != "" {
+		hashed = newHashingOutput(out)
+		out = hashed
+	}
+
+	start := time.Now()
+	err = build(out)
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	logDuration("build", "built site", start)
+
+	if hashed != nil {
+		err = writeChanges(hashed)
+		if err != nil {
+			log.Printf("[gen/changes] unable to write changes: %s", err)
+			return 1
+		}
+	}
+
+	report.Print()
+	if *flagStrict && len(report.Problems) > 0 {
+		log.Printf("[gen/report] failing build under -strict")
+		return 1
+	}
+
+	if warnings := markdownWarnings.Load(); *flagStrictMarkdown && warnings > 0 {
+		log.Printf("[gen/report] failing build on %d markdown warnings under -strict-markdown", warnings)
+		return 1
+	}
+
+	if missing := missingReferences.Load(); missing > 0 {
+		log.Printf("[gen/report] failing build on %d missing references with OnMissing fail", missing)
+		return 1
+	}
+
+	if cfg.PostBuild != "" {
+		err = runHook("postbuild", cfg.PostBuild)
+		if err != nil {
+			log.Print(err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// prepareBuild resets the build state and loads the templates shared by
+// full builds and single page renders.
+func prepareBuild(out outputFS) error {
+	output = out
+
 	reHref = *regexp.MustCompile(`<a\s+(?:[^>]*?\s+)?(?:href=")(\/.*?)(?:")`)
 	reExtHref = *regexp.MustCompile(`<a\s+(?:[^>]*?\s+)?(?:href=")(http.*?)(?:")`)
+	siteContext = newSite()
+	conditionalAssets = make(map[string]string)
+	movedAssets = make(map[string]string)
+	movedOwners = make(map[string]string)
+	copiedFiles = make(map[string]string)
+	copiedSources = make(map[string]string)
+	sectionContexts = make(map[string]map[string]interface{})
+	ampPages.Store(false)
+	missingReferences.Store(0)
+	markdownHTMLFlags = resolveHTMLFlags(cfg.HTMLFlags)
+	markdownExtensions = resolveExtensions(cfg.CommonMarkStrict, cfg.ParserExtensions)
 
 	var err error
-	mdTemplate, err = template.ParseFiles("template/markdown.html")
+	contentFS, err = openContent(cfg.ContentArchive)
 	if err != nil {
-		log.Printf("[gen/init/template] unable to open markdown template: %s", err)
-		return
-	} else {
-		log.Printf("[gen/init/template] opened markdown template")
+		return err
 	}
 
-	footerTemplate, err = template.ParseFiles("template/footer.html")
+	siteLocation, err = loadLocation(cfg.Timezone)
 	if err != nil {
-		log.Printf("[gen/init/template] unable to open footer template: %s", err)
-		return
-	} else {
-		log.Printf("[gen/init/template] opened footer template")
+		return err
 	}
 
-	sitemapTemplate, err = template.ParseFiles("template/sitemap.html")
+	lastCommits = nil
+	if cfg.LastCommit || cfg.RecentFromGit {
+		lastCommits = loadLastCommits()
+	}
+
+	assetManifest, err = loadAssetManifest(cfg.AssetManifest)
 	if err != nil {
-		log.Printf("[gen/init/template] unable to open sitemap template: %s", err)
-		return
+		return err
+	}
+
+	criticalCSS, err = loadCriticalCSS(cfg.CriticalCSS)
+	if err != nil {
+		return err
+	}
+
+	return loadTemplates()
+}
+
+// loadTemplates parses the templates and clears what's cached from them and
+// the includes, leaving what parsing the content recorded, like moved and
+// copied assets, so -watch can re-render pages with changed templates.
+func loadTemplates() error {
+	layoutTemplates = make(map[string]*template.Template)
+	layoutImports = make(map[string]template.HTML)
+	includeCache = make(map[string]template.HTML)
+	outputTemplates = make(map[string]*texttemplate.Template)
+
+	var err error
+	mdTemplate, err = loadTemplate("markdown", templatePath("markdown.html"))
+	if err != nil {
+		return err
+	}
+
+	footerTemplate, err = loadTemplate("footer", templatePath("footer.html"))
+	if err != nil {
+		return err
+	}
+
+	sitemapTemplate, err = loadTemplate("sitemap", templatePath("sitemap.html"))
+	if err != nil {
+		return err
+	}
+
+	if cfg.EditURL != "" {
+		editURLTemplate, err = texttemplate.New("EditURL").Parse(cfg.EditURL)
+		if err != nil {
+			return fmt.Errorf("[gen/init/template] unable to parse edit url template: %s", err)
+		}
+	}
+
+	encryptedTemplate, err = loadEncryptedTemplate(configuredTemplate(cfg.EncryptedTemplate, "encrypted.html"))
+	if err != nil {
+		return fmt.Errorf("[gen/init/template] unable to open encrypted template: %s", err)
 	} else {
-		log.Printf("[gen/init/template] opened sitemap template")
+		log.Printf("[gen/init/template] opened encrypted template")
+	}
+
+	archivePath := configuredTemplate(cfg.ArchiveTemplate, "archive.html")
+	if _, err := os.Stat(archivePath); err == nil {
+		archiveTemplate, err = loadTemplate("archive", archivePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	recentPath := configuredTemplate(cfg.RecentTemplate, "recent.html")
+	if _, err := os.Stat(recentPath); err == nil {
+		recentTemplate, err = loadTemplate("recent", recentPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	ampPath := configuredTemplate(cfg.AMPTemplate, "amp.html")
+	if _, err := os.Stat(ampPath); err == nil {
+		ampTemplate, err = loadTemplate("amp", ampPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseSite walks the content into pages and links them together, returning
+// the external links found.
+func parseSite() (map[string]string, error) {
+	err := copyThemeStatic()
+	if err != nil {
+		return nil, fmt.Errorf("[gen/theme/static] unable to copy theme static files: %s", err)
+	}
+
+	start := time.Now()
+	err = parseDirectoryContent("content", "gen")
+	if err != nil {
+		return nil, err
 	}
+	logDuration("parse", fmt.Sprintf("parsed %d pages", len(pages)), start)
 
-	parseDirectoryContent("content", "gen")
+	linkTranslations(pages)
 
-	log.Printf("[gen/parse] parsed %d pages", len(pages))
+	siteContext.Nav = buildNavTree(pages)
+	siteContext.Collections = buildCollections(pages, cfg.Collections)
+	siteContext.Menus = buildMenus(pages, cfg.Menus)
+	linkChildren(pages)
+	siteContext.Stats = collectStats(pages)
 
 	externalLinks := make(map[string]string)
 
+	byLink := make(map[string]*page, len(pages))
+	for _, page := range pages {
+		if page.hasHTMLOutput() {
+			byLink[pageLink(page.OutPath)] = page
+		}
+	}
+
 	for key, page := range pages {
-		if page.Type != "" {
+		if page.Type != "" && page.hasHTMLOutput() {
 			log.Printf("[gen/parse/extlinks] parsing %s as %s", page.OutPath, key)
 			extLinks := reExtHref.FindAllStringSubmatch(string(page.Content), -1)
 			for _, extLink := range extLinks {
@@ -127,64 +496,148 @@ func main() {
 			}
 
 			log.Printf("[gen/parse/backlinks] parsing %s as %s", page.OutPath, key)
-			links := reHref.FindAllStringSubmatch(string(page.Content), -1)
-			for _, link := range links {
-				log.Printf("[gen/parse/backlinks] found link in %s: %s", page.OutPath, link[1])
-				p := fmt.Sprintf("public%s", link[1])
-				if targetPage, ok := pages[p]; ok {
-					targetPage.Backlinks[strings.Replace(page.OutPath, "public", "", 1)] = page.Name
+			content := string(page.Content)
+			links := reHref.FindAllStringSubmatchIndex(content, -1)
+			for _, match := range links {
+				link := content[match[2]:match[3]]
+				log.Printf("[gen/parse/backlinks] found link in %s: %s", page.OutPath, link)
+				if i := strings.IndexAny(link, "?#"); i >= 0 {
+					link = link[:i]
+				}
+				link = canonicalURL(slugPath(link))
+				if targetPage, ok := byLink[link]; ok {
+					targetPage.Backlinks[pageLink(page.OutPath)] = newBacklink(page, match[0])
 				} else {
-					p = fmt.Sprintf("public%s/index.html", link[1])
-					if targetPage, ok := pages[p]; ok {
-						targetPage.Backlinks[strings.Replace(page.OutPath, "public", "", 1)] = page.Name
-					} else {
-						log.Printf("[gen/parse/backlinks] unable to find page %s", p)
-					}
+					log.Printf("[gen/parse/backlinks] unable to find page %s", link)
 				}
 			}
 		}
 	}
 
-	for _, page := range pages {
-		page.Render()
+	return externalLinks, nil
+}
+
+func build(out outputFS) error {
+	err := prepareBuild(out)
+	if err != nil {
+		return err
 	}
 
-	internalLinks := make(map[string]string)
+	externalLinks, err := parseSite()
+	if err != nil {
+		return err
+	}
 
-	for _, page := range pages {
-		if page.Type != "" {
-			internalLinks[strings.Replace(page.OutPath, "public/", "", 1)] = strings.Replace(page.OutPath, "public/", "", 1)
+	if cfg.OGImages {
+		err = generateOGImages(pages)
+		if err != nil {
+			return err
 		}
 	}
 
-	sitemap, err := NewPage("content/sitemap.html", "public/sitemap.html", "Sitemap")
+	start := time.Now()
+	forEachPage(pages, func(p *page) {
+		p.Render()
+	})
+
+	logDuration("render", "rendered pages", start)
+	templateDependents = mapTemplateDependents(pages)
+
+	copyReferencedAssets(pages)
+	lintAssetReferences(pages)
+	checkIcons(cfg)
+
+	internalLinks := make(map[string]string)
+
+	for _, page := range sitemapPages(pages) {
+		url := strings.TrimPrefix(pageLink(page.OutPath), "/")
+		internalLinks[url] = url
+	}
+
+	sitemap, err := NewPage(filepath.Join("content", "sitemap.html"), filepath.Join("public", "sitemap.html"), "Sitemap")
 	if err != nil {
-		log.Print(err)
-		return
+		return err
 	}
 
 	sitemap.InternalLinks = internalLinks
 	sitemap.ExternalLinks = externalLinks
 
 	renderSitemap(sitemap)
+
+	sitemapLocation := strings.TrimSuffix(cfg.BaseURL, "/") + "/sitemap.html"
+	if cfg.BaseURL != "" {
+		sitemapLocation = renderSitemapXML(sitemapPages(pages))
+	}
+
+	if cfg.Feed {
+		renderFeed(pages)
+	}
+
+	if cfg.LLMsTxt {
+		generateLLMsTxt(pages, cfg)
+	}
+
+	if cfg.API {
+		generateAPI(pages)
+	}
+
+	writeAccessManifest(pages)
+
+	if len(cfg.RobotsRules) > 0 {
+		renderRobots(sitemapLocation)
+	}
+
+	if archiveTemplate != nil {
+		archive, err := NewPage(filepath.Join("content", "archive", "index.html"), filepath.Join("public", "archive", "index.html"), "Archive")
+		if err != nil {
+			return err
+		}
+
+		archive.Archive = generateArchive(pages)
+
+		renderArchive(archive)
+	}
+
+	if cfg.Recent > 0 {
+		err = renderRecent(pages)
+		if err != nil {
+			return err
+		}
+	}
+
+	if cfg.VerifyOutput {
+		verifyOutputs(pages)
+	}
+
+	return nil
 }
 
-func parseDirectoryContent(directory, parent string) {
-	inodes, err := os.ReadDir(directory)
+// parseDirectoryContent parses every source below directory into pages.
+// parent is the directory's name, the root's being gen, and names the index
+// page of the directory unless its front matter sets a title. Directories
+// that can't be read fail the parse.
+func parseDirectoryContent(directory, parent string) error {
+	inodes, err := readSourceDir(directory)
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("[gen/parse/dir] %s", err)
 	}
 
-	os.Mkdir("public", fs.FileMode(0700))
+	output.MkdirAll("public")
+	attachments := attachmentDirs(inodes)
 	for _, inode := range inodes {
-		path := fmt.Sprintf("%s/%s", directory, inode.Name())
-		outPath := strings.ToLower(path)
-		outPath = strings.Replace(outPath, "content/", "", 1)
-		outPath = strings.Replace(outPath, " ", "_", -1)
-		outPath = strings.Replace(outPath, ".md", ".html", 1)
-		outPath = fmt.Sprintf("public/%s", outPath)
+		if !inode.IsDir() && inode.Name() == sectionContextFile || attachments[inode.Name()] {
+			continue
+		}
+
+		path := filepath.Join(directory, inode.Name())
+		outPath := outputPath(path)
 		if inode.IsDir() {
-			err := os.MkdirAll(outPath, 0700)
+			if inode.Name() == draftsDirectory && !*flagDrafts {
+				log.Printf("[gen/process/dir] skipping drafts directory %s", path)
+				continue
+			}
+
+			err := output.MkdirAll(outPath)
 			if err != nil {
 				log.Printf("[gen/process/dir] unable to create directory %s: %s", outPath, err)
 				continue
@@ -192,70 +645,422 @@ func parseDirectoryContent(directory, parent string) {
 				log.Printf("[gen/process/dir] created directory %s", outPath)
 			}
 
-			childName := directory
-			if childName == "content" {
-				childName = parent
+			err = parseDirectoryContent(path, inode.Name())
+			if err != nil {
+				return err
 			}
 
-			parseDirectoryContent(path, childName)
-
 		} else {
-			s, err := os.ReadFile(path)
-			if err != nil {
-				log.Printf("[gen/parse/source] unable to read source %s: %s", outPath, err)
-				continue
+			p := parseFile(path, outPath, parent)
+			if p != nil {
+				addPage(p)
 			}
+		}
+	}
+
+	return nil
+}
+
+// parseFile reads a single content file into a page, copying it to outPath
+// when it isn't a page itself. Pages that are skipped return nil.
+func parseFile(path, outPath, parent string) *page {
+	var s []byte
+	err := retryIO("read", path, func() (err error) {
+		s, err = readSource(path)
+		return err
+	})
+	if err != nil {
+		log.Printf("[gen/parse/source] unable to read source %s: %s", outPath, err)
+		return nil
+	}
+
+	name := filepath.Base(path)
+	p, err := NewPage(path, outPath, strings.TrimSuffix(name, filepath.Ext(name)))
+	if err != nil {
+		log.Print(err)
+		return nil
+	}
+
+	p.setSourcePath()
+
+	if info, err := statSource(path); err == nil {
+		p.ModTime = info.ModTime().In(siteLocation)
+	}
+	p.setLastCommit()
+
+	command, processed := processor(name)
+	if filepath.Ext(name) == ".html" || isMarkdown(name) || processed {
+		p.localise()
+	}
+
+	if p.Name == "index" {
+		p.Name = parent
+	}
+	p.Title = displayName(p.Name)
+
+	switch ext := filepath.Ext(name); {
+	case ext == ".html":
+		p.Type = "HTML"
+		p.Meta, s = parseFrontMatter(s)
+		p.Content = template.HTML(s)
+
+	case isMarkdown(ext):
+		p.Meta, s = parseFrontMatter(s)
+		var info markdownInfo
+		p.markdownOptions = pageMarkdownOptions(path, p.Meta["markdown"])
+		s, p.Content, info = pagePipeline.run(path, s, p.markdownOptions)
+		p.Type = "MD"
+
+		for _, warning := range info.Warnings {
+			markdownWarnings.Add(1)
+			if *flagStrictMarkdown {
+				report.Add("validate/markdown", path, "%s", warning)
+			} else {
+				log.Printf("[gen/validate/markdown] %s: %s", path, warning)
+			}
+		}
+
+		if info.HasMermaid {
+			p.extraImports += mermaidImport
+		}
+
+	case processed:
+		p.Meta, s = parseFrontMatter(s)
+		p.Content, err = runProcessor(path, command, s)
+		if err != nil {
+			report.Add("parse/processor", path, "skipping page, %s", err)
+			return nil
+		}
+		p.Content = pagePipeline.postProcess(path, p.Content)
+		p.Type = "MD"
+
+		// The summary delimiter is for markdown sources, summaries of
+		// processed pages come from their html.
+		s = nil
+
+	default:
+		outPath = assetOutPath(path, outPath)
+		p.OutPath = outPath
+
+		if deferAsset(path, outPath) {
+			break
+		}
 
-			p, err := NewPage(path, outPath, strings.TrimSuffix(inode.Name(), filepath.Ext(inode.Name())))
+		log.Printf("[gen/process/file] copying %s", path)
+		err = copyFile(path, outPath)
+		if err != nil {
+			log.Printf("[gen/process/file] unable to copy %s: %s", path, err)
+			return nil
+		}
+	}
+
+	p.applyMeta()
+
+	if p.Type != "" {
+		p.Context = pageContext(path, p.Meta)
+
+		base, err := layoutStaticImports(p.Layout)
+		if err != nil {
+			log.Printf("[gen/parse/layout] unable to open static imports partial for %s: %s", path, err)
+		} else {
+			p.StaticImports = base
+		}
+		p.StaticImports += p.extraImports
+	}
+
+	if p.Type != "" {
+		p.setSummary(s)
+		p.setDescription()
+	}
+
+	if p.Draft && !*flagDrafts {
+		log.Printf("[gen/parse/draft] skipping draft %s", path)
+		return nil
+	}
+
+	if !p.matchesBuildTags() {
+		log.Printf("[gen/parse/tags] skipping %s, its build tags %s don't match -tags %q", path, p.Meta["buildtags"], *flagTags)
+		return nil
+	}
+
+	if p.Type != "" {
+		p.validateRequiredFields()
+	}
+
+	if password, ok := p.Meta["password"]; ok {
+		delete(p.Meta, "password")
+
+		if p.Type != "MD" {
+			log.Printf("[gen/parse/encrypt] password is only supported on markdown pages, ignoring for %s", path)
+		} else {
+			content, err := encryptContent(p.Content, password)
 			if err != nil {
-				log.Print(err)
-				continue
+				log.Printf("[gen/parse/encrypt] skipping %s: %s", path, err)
+				return nil
 			}
 
-			if p.Name == "index" {
-				p.Name = parent
+			p.Content = content
+			p.Summary = ""
+			if p.Meta["description"] == "" {
+				p.Description = ""
 			}
+			p.Encrypted = true
+			log.Printf("[gen/parse/encrypt] encrypted %s", path)
+		}
+	}
 
-			switch filepath.Ext(inode.Name()) {
-			case ".html":
-				p.Type = "HTML"
-				p.Content = template.HTML(s)
+	if p.Type != "" {
+		p.addAttachments(path)
+	}
 
-			case ".md":
-				p.Content = markdown2html(s)
-				p.Type = "MD"
+	p.setLead()
+	p.splitSections()
+	p.setAMP()
+	p.setOGImage()
 
-			default:
-				log.Printf("[gen/process/file] copying %s", path)
-				copyFile(path, outPath)
-			}
+	if p.OutPath != outPath {
+		log.Printf("[gen/parse/i18n] localised %s as %s", path, p.OutPath)
+	}
+
+	return &p
+}
+
+const draftsDirectory = "_drafts"
+
+// outputPath maps a source path under content to its output path under
+// public, lowercased with spaces replaced, each segment sanitized, and
+// markdown rendered to html. Pages in _drafts directories map to the path
+// they'll have once published.
+func outputPath(path string) string {
+	outPath := strings.ToLower(filepath.ToSlash(path))
+	outPath = strings.Replace(outPath, "content/", "", 1)
+	outPath = strings.Replace(outPath, " ", "_", -1)
+
+	segments := make([]string, 0)
+	for _, segment := range strings.Split(outPath, "/") {
+		if segment != draftsDirectory {
+			segments = append(segments, segment)
+		}
+	}
+	outPath = strings.Join(segments, "/")
+
+	if _, processed := processor(outPath); isMarkdown(outPath) || processed {
+		outPath = strings.TrimSuffix(outPath, filepath.Ext(outPath)) + ".html"
+	}
+
+	segments = strings.Split(outPath, "/")
+	for i, segment := range segments {
+		if segment != "" {
+			segments[i] = sanitizeSegment(segment)
+		}
+	}
+	outPath = strings.Join(segments, "/")
+
+	return filepath.Join("public", filepath.FromSlash(outPath))
+}
+
+// pageURL converts an output path to its site relative url, always using
+// forward slashes regardless of the platform separator.
+func pageURL(outPath string) string {
+	return strings.TrimPrefix(filepath.ToSlash(outPath), "public")
+}
+
+// pageLink is the url links to a page use, in the canonical form.
+func pageLink(outPath string) string {
+	return canonicalURL(pageURL(outPath))
+}
+
+// canonicalURL puts a site relative url to a page in the single form set by
+// URLStyle, so links generated and links resolved compare equal. The index
+// style, the default, names index.html and pretty leaves it off, ending in a
+// slash. Links without an extension are taken to be directories.
+func canonicalURL(url string) string {
+	if url == "" {
+		return url
+	}
 
-			pages[strings.Replace(p.OutPath, "/content", "", 1)] = &p
+	if cfg.URLStyle == "pretty" {
+		switch {
+		case path.Base(url) == "index.html":
+			return strings.TrimSuffix(url, "index.html")
+		case !strings.HasSuffix(url, "/") && path.Ext(url) == "":
+			return url + "/"
+		}
+
+		return url
+	}
+
+	switch {
+	case strings.HasSuffix(url, "/"):
+		return url + "index.html"
+	case path.Ext(url) == "":
+		return url + "/index.html"
+	}
+
+	return url
+}
+
+// setSourcePath records the source path relative to the content root and
+// renders the configured edit url for it.
+func (p *page) setSourcePath() {
+	rel, err := filepath.Rel("content", p.Path)
+	if err != nil {
+		rel = p.Path
+	}
+	p.SourcePath = filepath.ToSlash(rel)
+
+	if editURLTemplate != nil {
+		var result bytes.Buffer
+		err = editURLTemplate.Execute(&result, p)
+		if err != nil {
+			log.Printf("[gen/parse/editurl] unable to render edit url for %s: %s", p.Path, err)
+			return
+		}
+		p.EditURL = result.String()
+	}
+}
+
+// applyMeta populates page fields from parsed front matter.
+func (p *page) applyMeta() {
+	if title, ok := p.Meta["title"]; ok && title != "" {
+		p.Title = title
+	}
+
+	if date, ok := p.Meta["date"]; ok && date != "" {
+		d, err := parseDate(date)
+		if err != nil {
+			report.Add("parse/meta", p.Path, "unable to parse date: %s", err)
+		} else {
+			p.Date = d
+		}
+	}
+
+	p.Draft = isDraftPath(p.Path)
+	if draft, ok := p.Meta["draft"]; ok {
+		p.Draft = parseBool(draft)
+	}
+
+	if hidden, ok := p.Meta["hidden"]; ok {
+		p.Hidden = parseBool(hidden)
+	}
+
+	p.Layout = p.Meta["layout"]
+	p.applySlug()
+
+	if noindex, ok := p.Meta["noindex"]; ok && parseBool(noindex) {
+		p.NoIndex = true
+		p.RobotsMeta = `<meta name="robots" content="noindex">`
+	}
+
+	p.setRoles()
+
+	if weight, ok := p.Meta["weight"]; ok {
+		w, err := strconv.Atoi(weight)
+		if err != nil {
+			log.Printf("[gen/parse/meta] unable to parse weight in %s: %s", p.Path, err)
+		} else {
+			p.Weight = w
+		}
+	}
+
+	if outputs, ok := p.Meta["outputs"]; ok {
+		p.Outputs = make([]string, 0)
+		for _, format := range parseList(outputs) {
+			p.Outputs = append(p.Outputs, strings.ToLower(strings.TrimPrefix(format, ".")))
+		}
+	}
+
+	p.setTitleFromHeading()
+
+	if p.titleCased() {
+		p.Title = titleCase(p.Title)
+	}
+}
+
+// isMarkdown reports whether the path has one of the configured markdown
+// extensions.
+func isMarkdown(path string) bool {
+	ext := filepath.Ext(path)
+	for _, markdownExt := range cfg.MarkdownExtensions {
+		if strings.EqualFold(ext, markdownExt) {
+			return true
+		}
+	}
+
+	return false
+}
+
+const mermaidImport = `<script type="module">
+import mermaid from "https://cdn.jsdelivr.net/npm/mermaid@10/dist/mermaid.esm.min.mjs";
+mermaid.initialize({ startOnLoad: true });
+</script>
+`
+
+// isDraftPath reports whether the source path is inside a _drafts directory.
+func isDraftPath(path string) bool {
+	for _, segment := range strings.Split(filepath.ToSlash(path), "/") {
+		if segment == draftsDirectory {
+			return true
 		}
 	}
+
+	return false
+}
+
+// markdownInfo describes what was found while rendering a markdown document.
+type markdownInfo struct {
+	// HasMermaid is set when the document contains mermaid diagrams that need
+	// the mermaid script loaded.
+	HasMermaid bool
+	// Warnings are problems gomarkdown silently tolerated.
+	Warnings []string
 }
 
-func markdown2html(md []byte) template.HTML {
+// markdown2html renders markdown to HTML, reporting what it found along the way.
+func markdown2html(md []byte, options markdownOptions) (template.HTML, markdownInfo) {
 	// create markdown parser with extensions
-	extensions := parser.CommonExtensions | parser.NoEmptyLineBeforeBlock
-	p := parser.NewWithExtensions(extensions)
+	p := parser.NewWithExtensions(options.Extensions)
 	doc := p.Parse(md)
 
+	info := markdownInfo{
+		Warnings: lintMarkdown(doc),
+	}
+
 	// create HTML renderer with extensions
-	htmlFlags := html.CommonFlags | html.HrefTargetBlank
-	opts := html.RendererOptions{Flags: htmlFlags}
+	htmlFlags := options.HTMLFlags
+	opts := html.RendererOptions{
+		Flags: htmlFlags,
+		RenderNodeHook: func(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+			block, ok := node.(*ast.CodeBlock)
+			if !ok || string(block.Info) != "mermaid" {
+				return ast.GoToNext, false
+			}
+
+			info.HasMermaid = true
+			io.WriteString(w, `<pre class="mermaid">`)
+			html.EscapeHTML(w, block.Literal)
+			io.WriteString(w, "</pre>\n")
+
+			return ast.GoToNext, true
+		},
+	}
 	renderer := html.NewRenderer(opts)
 
-	return template.HTML(markdown.Render(doc, renderer))
+	return template.HTML(markdown.Render(doc, renderer)), info
 }
 
 func renderMd(p page) {
-	f, err := os.Create(p.OutPath)
+	f, err := output.Create(p.OutPath)
 	if err != nil {
 		log.Printf("[gen/render/file] unable to create file %s: %s", p.OutPath, err)
 		return
 	} else {
-		err = mdTemplate.Execute(f, p)
+		defer f.Close()
+		var rendered bytes.Buffer
+		err = layoutTemplate(p.Layout).Execute(&rendered, p)
+		if err == nil {
+			err = writeTransformed(f, rendered.Bytes(), &p)
+		}
 		if err != nil {
 			log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
 			return
@@ -266,11 +1071,12 @@ func renderMd(p page) {
 }
 
 func renderSitemap(p page) {
-	f, err := os.Create(p.OutPath)
+	f, err := output.Create(p.OutPath)
 	if err != nil {
 		log.Printf("[gen/render/file] unable to create file %s: %s", p.OutPath, err)
 		return
 	} else {
+		defer f.Close()
 		err = sitemapTemplate.Execute(f, p)
 		if err != nil {
 			log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
@@ -281,19 +1087,45 @@ func renderSitemap(p page) {
 	}
 }
 
+// renderHtml executes an html source as a template and writes it through the
+// transforms. Raw sources are written verbatim instead, and sources without
+// any template actions, or that don't parse as a template unless they're
+// marked as one, are written through the transforms untemplated.
 func renderHtml(p page) {
-	source, err := template.ParseFiles(p.Path)
-	if err != nil {
-		log.Printf("[gen/render/dir] unable to open source file: %s", err)
-		return
+	mode := p.htmlMode()
+
+	var rendered bytes.Buffer
+	if mode == "raw" || mode == "" && !strings.Contains(string(p.Content), cfg.LeftDelim) {
+		rendered.WriteString(string(p.Content))
+	} else {
+		source, err := template.New(filepath.Base(p.Path)).Funcs(templateFuncs).Delims(cfg.LeftDelim, cfg.RightDelim).Parse(string(p.Content))
+		switch {
+		case err != nil && mode == "template":
+			log.Printf("[gen/render/dir] unable to open source file: %s", err)
+			return
+		case err != nil:
+			report.Add("render/html", p.Path, "doesn't parse as a template, writing it untemplated, set html: raw or html: template: %s", err)
+			rendered.WriteString(string(p.Content))
+		default:
+			err = source.Execute(&rendered, p)
+			if err != nil {
+				log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
+				return
+			}
+		}
 	}
 
-	f, err := os.Create(p.OutPath)
+	f, err := output.Create(p.OutPath)
 	if err != nil {
 		log.Printf("[gen/render/file] unable to create file %s: %s", p.OutPath, err)
 		return
 	} else {
-		err = source.Execute(f, p)
+		defer f.Close()
+		if mode == "raw" {
+			_, err = f.Write(rendered.Bytes())
+		} else {
+			err = writeTransformed(f, rendered.Bytes(), &p)
+		}
 		if err != nil {
 			log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
 			return
@@ -303,22 +1135,91 @@ func renderHtml(p page) {
 	}
 }
 
-func copyFile(path, outPath string) {
-	fin, err := os.Open(path)
+// htmlMode is how an html source is rendered, raw from html: raw front
+// matter or the RawHTML url globs, template from html: template, or empty to
+// decide from the source.
+func (p *page) htmlMode() string {
+	switch mode := p.Meta["html"]; mode {
+	case "raw", "template":
+		return mode
+	case "":
+	default:
+		report.Add("render/html", p.Path, "unknown html mode %q, expected raw or template", mode)
+	}
+
+	if matchesAny(cfg.RawHTML, pageURL(p.OutPath)) {
+		return "raw"
+	}
+
+	return ""
+}
+
+// copiedSources maps the files copied this build to their source, so a
+// destination written from one source isn't taken as up to date for another.
+var (
+	copiedSources   = make(map[string]string)
+	copiedSourcesMu sync.Mutex
+)
+
+// copyFile streams a file into the output, keeping its permissions and
+// modification time. Destinations with the same size and modification time
+// as the source are assumed to be up to date and left alone, unless another
+// source was copied to them earlier in the build. With
+// DedupeFiles, files with the same content as one already copied are linked
+// to it instead, and existing files are removed before they're written so
+// a change never reaches the files linked to them.
+func copyFile(path, outPath string) error {
+	var fin fs.File
+	err := retryIO("open", path, func() (err error) {
+		fin, err = openSource(path)
+		return err
+	})
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 	defer fin.Close()
 
-	fout, err := os.Create(outPath)
+	info, err := fin.Stat()
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	if cfg.DedupeFiles != "" && linkDuplicate(path, outPath) {
+		return nil
+	}
+
+	copiedSourcesMu.Lock()
+	previous, copied := copiedSources[outPath]
+	copiedSources[outPath] = path
+	copiedSourcesMu.Unlock()
+
+	if existing, err := output.Stat(outPath); err == nil && (!copied || previous == path) && existing.Size() == info.Size() && existing.ModTime().Equal(info.ModTime()) {
+		log.Printf("[gen/process/file] %s is up to date", outPath)
+		return output.Keep(outPath)
+	}
+
+	if cfg.DedupeFiles != "" {
+		err = output.Remove(outPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	fout, err := output.Create(outPath)
+	if err != nil {
+		return err
 	}
-	defer fout.Close()
 
 	_, err = io.Copy(fout, fin)
+	if err != nil {
+		fout.Close()
+		return err
+	}
 
+	err = fout.Close()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
+
+	return output.SetInfo(outPath, info.Mode().Perm(), info.ModTime())
 }
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestOutputPath(t *testing.T) {
+	cfg = defaultConfig()
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		windows bool
+	}{
+		{"markdown", filepath.Join("content", "post.md"), filepath.Join("public", "post.html"), false},
+		{"nested", filepath.Join("content", "Docs", "Intro Page.md"), filepath.Join("public", "docs", "intro_page.html"), false},
+		{"asset", filepath.Join("content", "img", "logo.png"), filepath.Join("public", "img", "logo.png"), false},
+		{"drafts", filepath.Join("content", "_drafts", "idea.md"), filepath.Join("public", "idea.html"), false},
+		{"forward slashes", "content/docs/intro.md", filepath.Join("public", "docs", "intro.html"), false},
+		{"backslashes", `content\docs\intro.md`, filepath.Join("public", "docs", "intro.html"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.windows && runtime.GOOS != "windows" {
+				t.Skip("backslashes only separate paths on windows")
+			}
+
+			if got := outputPath(tt.path); got != tt.want {
+				t.Errorf("outputPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		outPath string
+		want    string
+		windows bool
+	}{
+		{"root", filepath.Join("public", "index.html"), "/index.html", false},
+		{"nested", filepath.Join("public", "docs", "intro.html"), "/docs/intro.html", false},
+		{"forward slashes", "public/docs/intro.html", "/docs/intro.html", false},
+		{"backslashes", `public\docs\intro.html`, "/docs/intro.html", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.windows && runtime.GOOS != "windows" {
+				t.Skip("backslashes only separate paths on windows")
+			}
+
+			if got := pageURL(tt.outPath); got != tt.want {
+				t.Errorf("pageURL(%q) = %q, want %q", tt.outPath, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCopyFilePreservesModeAndModTime(t *testing.T) {
+	cfg = defaultConfig()
+	output = diskOutput{}
+	copiedSources = make(map[string]string)
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.bin")
+	dest := filepath.Join(dir, "dest.bin")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeSource(t, source, "binary\x00data", 0640, modTime)
+
+	err := copyFile(source, dest)
+	if err != nil {
+		t.Fatalf("copyFile: %s", err)
+	}
+
+	info, err := os.Stat(dest)
+	if err != nil {
+		t.Fatalf("stat copy: %s", err)
+	}
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("copy has mode %s, want %s", info.Mode().Perm(), fs.FileMode(0640))
+	}
+	if !info.ModTime().Equal(modTime) {
+		t.Errorf("copy modified at %s, want %s", info.ModTime(), modTime)
+	}
+	if b, _ := os.ReadFile(dest); string(b) != "binary\x00data" {
+		t.Errorf("copy holds %q, want %q", b, "binary\x00data")
+	}
+}
+
+func TestCopyFileSameSizeAndModTimeFromAnotherSource(t *testing.T) {
+	cfg = defaultConfig()
+	output = diskOutput{}
+	copiedSources = make(map[string]string)
+
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a", "logo.png")
+	second := filepath.Join(dir, "b", "logo.png")
+	dest := filepath.Join(dir, "logo.png")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeSource(t, first, "aaaa", 0600, modTime)
+	writeSource(t, second, "bbbb", 0600, modTime)
+
+	for _, source := range []string{first, second} {
+		err := copyFile(source, dest)
+		if err != nil {
+			t.Fatalf("copyFile(%s): %s", source, err)
+		}
+	}
+
+	if b, _ := os.ReadFile(dest); string(b) != "bbbb" {
+		t.Errorf("destination holds %q, want the second source's %q", b, "bbbb")
+	}
+}
+
+func TestCopyFileSkipsUpToDate(t *testing.T) {
+	cfg = defaultConfig()
+	output = diskOutput{}
+	copiedSources = make(map[string]string)
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	dest := filepath.Join(dir, "dest.txt")
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	writeSource(t, source, "new!", 0600, modTime)
+	writeSource(t, dest, "old!", 0600, modTime)
+
+	err := copyFile(source, dest)
+	if err != nil {
+		t.Fatalf("copyFile: %s", err)
+	}
+
+	if b, _ := os.ReadFile(dest); string(b) != "old!" {
+		t.Errorf("up to date destination was rewritten with %q", b)
+	}
+}
+
+func writeSource(t *testing.T, path, content string, mode fs.FileMode, modTime time.Time) {
+	t.Helper()
+
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = os.WriteFile(path, []byte(content), mode)
+	}
+	if err == nil {
+		err = os.Chmod(path, mode)
+	}
+	if err == nil {
+		err = os.Chtimes(path, modTime, modTime)
+	}
+	if err != nil {
+		t.Fatalf("write %s: %s", path, err)
+	}
+}
+
+// newTestSite writes files, by slash separated path, into a temporary project
+// alongside the templates a build needs, changes into it and prepares a
+// build into memory with an empty page set and report.
+func newTestSite(t *testing.T, files map[string]string) *memoryOutput {
+	t.Helper()
+
+	dir := t.TempDir()
+	templates := map[string]string{
+		"template/markdown.html":   `<html><head><title>{{.Title}}</title></head><body>{{.Content}}</body></html>`,
+		"template/footer.html":     `<footer></footer>`,
+		"template/sitemap.html":    `<html><body></body></html>`,
+		"template/navigation.html": `<nav></nav>`,
+		"template/static.html":     ``,
+	}
+	for name, content := range templates {
+		if _, ok := files[name]; !ok {
+			files[name] = content
+		}
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		err := os.MkdirAll(filepath.Dir(path), 0700)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0600)
+		}
+		if err != nil {
+			t.Fatalf("write %s: %s", name, err)
+		}
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.Chdir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		log.SetOutput(os.Stderr)
+	})
+
+	cfg = defaultConfig()
+	resetSite()
+
+	out := newMemoryOutput()
+	err = prepareBuild(out)
+	if err != nil {
+		t.Fatalf("prepareBuild: %s", err)
+	}
+
+	return out
+}
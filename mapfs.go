@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mapFS is an in memory file system keyed by slash separated paths, holding
+// the output of memory builds and content read from tar archives.
+// Directories exist when they're added with ModeDir or have files below.
+type mapFS map[string]*mapFile
+
+type mapFile struct {
+	Data    []byte
+	Mode    fs.FileMode
+	ModTime time.Time
+}
+
+func (m mapFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	f, ok := m[name]
+	if ok && !f.Mode.IsDir() {
+		return &mapOpenFile{Reader: bytes.NewReader(f.Data), info: mapInfo{name: name, file: f}}, nil
+	}
+
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+
+	children := make(map[string]mapInfo)
+	for key, child := range m {
+		rest, found := strings.CutPrefix(key, prefix)
+		if !found || rest == "" || key == name {
+			continue
+		}
+
+		if first, _, nested := strings.Cut(rest, "/"); nested {
+			if _, ok := children[first]; !ok {
+				children[first] = mapInfo{name: first}
+			}
+		} else {
+			children[rest] = mapInfo{name: rest, file: child}
+		}
+	}
+
+	if !ok && len(children) == 0 && name != "." {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	entries := make([]fs.DirEntry, 0, len(children))
+	for _, child := range children {
+		entries = append(entries, child)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return &mapOpenDir{info: mapInfo{name: name, file: f}, entries: entries}, nil
+}
+
+// mapInfo describes a file, or a directory without a file when it's only
+// implied by the files below it.
+type mapInfo struct {
+	name string
+	file *mapFile
+}
+
+func (i mapInfo) Name() string {
+	if i.name == "." {
+		return "."
+	}
+	return i.name[strings.LastIndex(i.name, "/")+1:]
+}
+
+func (i mapInfo) Size() int64 {
+	if i.file == nil {
+		return 0
+	}
+	return int64(len(i.file.Data))
+}
+
+func (i mapInfo) Mode() fs.FileMode {
+	if i.file == nil {
+		return fs.ModeDir | 0700
+	}
+	return i.file.Mode
+}
+
+func (i mapInfo) ModTime() time.Time {
+	if i.file == nil {
+		return time.Time{}
+	}
+	return i.file.ModTime
+}
+
+func (i mapInfo) IsDir() bool                { return i.Mode().IsDir() }
+func (i mapInfo) Sys() interface{}           { return nil }
+func (i mapInfo) Type() fs.FileMode          { return i.Mode().Type() }
+func (i mapInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type mapOpenFile struct {
+	*bytes.Reader
+	info mapInfo
+}
+
+func (f *mapOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *mapOpenFile) Close() error               { return nil }
+
+type mapOpenDir struct {
+	info    mapInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *mapOpenDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *mapOpenDir) Close() error               { return nil }
+
+func (d *mapOpenDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *mapOpenDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	d.offset += n
+	return remaining[:n], nil
+}
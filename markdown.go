@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/html"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+var htmlFlagNames = map[string]html.Flags{
+	"SkipHTML":                html.SkipHTML,
+	"SkipImages":              html.SkipImages,
+	"SkipLinks":               html.SkipLinks,
+	"Safelink":                html.Safelink,
+	"NofollowLinks":           html.NofollowLinks,
+	"NoreferrerLinks":         html.NoreferrerLinks,
+	"NoopenerLinks":           html.NoopenerLinks,
+	"HrefTargetBlank":         html.HrefTargetBlank,
+	"CompletePage":            html.CompletePage,
+	"UseXHTML":                html.UseXHTML,
+	"FootnoteReturnLinks":     html.FootnoteReturnLinks,
+	"FootnoteNoHRTag":         html.FootnoteNoHRTag,
+	"Smartypants":             html.Smartypants,
+	"SmartypantsFractions":    html.SmartypantsFractions,
+	"SmartypantsDashes":       html.SmartypantsDashes,
+	"SmartypantsLatexDashes":  html.SmartypantsLatexDashes,
+	"SmartypantsAngledQuotes": html.SmartypantsAngledQuotes,
+	"SmartypantsQuotesNBSP":   html.SmartypantsQuotesNBSP,
+	"TOC":                     html.TOC,
+	"LazyLoadImages":          html.LazyLoadImages,
+	"CommonFlags":             html.CommonFlags,
+}
+
+// parserExtensionNames are the parser extensions, all about spacing, that
+// ParserExtensions can toggle.
+var parserExtensionNames = map[string]parser.Extensions{
+	"NoEmptyLineBeforeBlock": parser.NoEmptyLineBeforeBlock,
+	"SpaceHeadings":          parser.SpaceHeadings,
+	"HardLineBreak":          parser.HardLineBreak,
+	"BackslashLineBreak":     parser.BackslashLineBreak,
+	"NonBlockingSpace":       parser.NonBlockingSpace,
+	"TabSizeEight":           parser.TabSizeEight,
+	"EmptyLinesBreakList":    parser.EmptyLinesBreakList,
+}
+
+const defaultMarkdownExtensions = parser.CommonExtensions | parser.NoEmptyLineBeforeBlock
+
+// commonMarkExtensions is the CommonMarkStrict preset. Indented lines and
+// lists numbered from anything but 1 continue a paragraph instead of
+// starting a block, newlines stay soft, headings need a space after the #s
+// and tabs are four columns. gomarkdown can't let a bullet list interrupt a
+// paragraph without the other blocks doing so too, so those still need a
+// blank line before them.
+const commonMarkExtensions = defaultMarkdownExtensions &^ parser.NoEmptyLineBeforeBlock
+
+var markdownExtensions = defaultMarkdownExtensions
+
+const defaultHTMLFlags = html.CommonFlags | html.HrefTargetBlank
+
+var markdownHTMLFlags = defaultHTMLFlags
+
+// markdownOptions are the parser extensions and renderer flags markdown is
+// rendered with, the site's unless a page overrides them.
+type markdownOptions struct {
+	Extensions parser.Extensions
+	HTMLFlags  html.Flags
+}
+
+func siteMarkdownOptions() markdownOptions {
+	return markdownOptions{Extensions: markdownExtensions, HTMLFlags: markdownHTMLFlags}
+}
+
+// pageMarkdownOptions applies a markdown front matter field, a list of html
+// flag names set to true or false like `Smartypants=false, SkipHTML=false`,
+// on top of the site options. A bare name enables the flag.
+func pageMarkdownOptions(path, value string) markdownOptions {
+	opts := siteMarkdownOptions()
+	if value == "" {
+		return opts
+	}
+
+	flags := make(map[string]bool)
+	for _, item := range parseList(value) {
+		name, setting, found := strings.Cut(item, "=")
+		flags[strings.TrimSpace(name)] = !found || parseBool(setting)
+	}
+
+	log.Printf("[gen/parse/markdown] overriding markdown options for %s", path)
+	opts.HTMLFlags = applyHTMLFlags(opts.HTMLFlags, flags)
+	return opts
+}
+
+// resolveExtensions applies the configured extension names on top of the
+// default or CommonMarkStrict parser extensions, true enables an extension
+// and false disables it.
+func resolveExtensions(commonMarkStrict bool, extensions map[string]bool) parser.Extensions {
+	resolved := defaultMarkdownExtensions
+	if commonMarkStrict {
+		resolved = commonMarkExtensions
+	}
+
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		extension, ok := parserExtensionNames[name]
+		if !ok {
+			log.Printf("[gen/init/config] ignoring unknown parser extension %s", name)
+			continue
+		}
+
+		if extensions[name] {
+			resolved |= extension
+		} else {
+			resolved &^= extension
+		}
+	}
+
+	return resolved
+}
+
+// resolveHTMLFlags applies the configured flag names on top of the default
+// renderer flags, true enables a flag and false disables it.
+func resolveHTMLFlags(flags map[string]bool) html.Flags {
+	return applyHTMLFlags(defaultHTMLFlags, flags)
+}
+
+func applyHTMLFlags(resolved html.Flags, flags map[string]bool) html.Flags {
+	names := make([]string, 0, len(flags))
+	for name := range flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		flag, ok := htmlFlagNames[name]
+		if !ok {
+			log.Printf("[gen/init/config] ignoring unknown html flag %s", name)
+			continue
+		}
+
+		if flags[name] {
+			resolved |= flag
+		} else {
+			resolved &^= flag
+		}
+	}
+
+	return resolved
+}
+
+var (
+	reEmphasisOpener  = regexp.MustCompile(`(?:^|[\s(\["'])([*_]+)\w`)
+	reEmphasisCloser  = regexp.MustCompile(`\w([*_]+)(?:[\s).,;:!?\]"']|$)`)
+	reBrokenReference = regexp.MustCompile(`\[[^\]]+\]\[[^\]]*\]`)
+)
+
+// markdownWarnings counts the warnings reported across the build.
+var markdownWarnings atomic.Int64
+
+// lintMarkdown walks the parsed document for text gomarkdown left as is
+// because it couldn't make sense of it, such as emphasis markers that were
+// never closed or reference links without a matching definition.
+//
+// A lone marker is valid markdown, like 5 * 3, *nix or f(*args), so emphasis
+// is only reported where text opens and later closes it with the same
+// character but the markers didn't pair, like *bold**, or where markers are
+// left over next to emphasis that was parsed, like **bold*. Code and math
+// aren't text nodes so aren't checked, and markers inside words like
+// snake_case are neither openers nor closers.
+func lintMarkdown(doc ast.Node) []string {
+	warnings := make([]string, 0)
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		text, ok := node.(*ast.Text)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		literal := string(text.Literal)
+		for _, match := range unpairedEmphasis(literal) {
+			warnings = append(warnings, fmt.Sprintf("unbalanced emphasis near %q", match))
+		}
+
+		if leftoverEmphasis(text) {
+			warnings = append(warnings, fmt.Sprintf("unbalanced emphasis near %q", strings.TrimSpace(literal)))
+		}
+
+		for _, match := range reBrokenReference.FindAllString(literal, -1) {
+			warnings = append(warnings, fmt.Sprintf("undefined reference link %s", match))
+		}
+
+		return ast.GoToNext
+	})
+
+	return warnings
+}
+
+// unpairedEmphasis returns the spans of literal text opened and closed by
+// runs of the same emphasis character.
+func unpairedEmphasis(literal string) []string {
+	closers := reEmphasisCloser.FindAllStringSubmatchIndex(literal, -1)
+
+	spans := make([]string, 0)
+	end := 0
+	for _, opener := range reEmphasisOpener.FindAllStringSubmatchIndex(literal, -1) {
+		if opener[2] < end {
+			continue
+		}
+
+		for _, closer := range closers {
+			if closer[2] > opener[3] && literal[closer[2]] == literal[opener[2]] {
+				spans = append(spans, literal[opener[2]:closer[3]])
+				end = closer[3]
+				break
+			}
+		}
+	}
+
+	return spans
+}
+
+// leftoverEmphasis reports whether text ends with markers right before
+// emphasis, or starts with them right after it.
+func leftoverEmphasis(text *ast.Text) bool {
+	literal := string(text.Literal)
+
+	before := strings.TrimRight(literal, "*_")
+	if before != literal && !strings.HasSuffix(before, " ") && isEmphasis(ast.GetNextNode(text)) {
+		return true
+	}
+
+	return strings.TrimLeft(literal, "*_") != literal && isEmphasis(ast.GetPrevNode(text))
+}
+
+func isEmphasis(node ast.Node) bool {
+	switch node.(type) {
+	case *ast.Emph, *ast.Strong:
+		return true
+	}
+
+	return false
+}
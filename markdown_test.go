@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestLintMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		md       string
+		warnings int
+	}{
+		{"emphasis", "Some *emphasis*, **strong** and _under_ text.", 0},
+		{"multiplication", "5 * 3 = 15 and x * y * z", 0},
+		{"lone markers", "the *nix way, f(*args, **kwargs) and a 5* rating", 0},
+		{"snake case", "use snake_case_words and my_var_ in __init__", 0},
+		{"code", "`*unbalanced` and\n\n```\n**not closed*\n```\n", 0},
+		{"escaped", "\\*not emphasis\\*", 0},
+		{"mismatched closer", "*bold** text", 1},
+		{"leftover opener", "**bold* text", 1},
+		{"leftover closer", "**bold*** text", 1},
+		{"broken reference", "see [the docs][missing]", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc := parser.NewWithExtensions(defaultMarkdownExtensions).Parse([]byte(tt.md))
+			if warnings := lintMarkdown(doc); len(warnings) != tt.warnings {
+				t.Errorf("lintMarkdown(%q) = %q, want %d warnings", tt.md, warnings, tt.warnings)
+			}
+		})
+	}
+}
+
+func TestLintMarkdownMath(t *testing.T) {
+	doc := parser.NewWithExtensions(defaultMarkdownExtensions | parser.MathJax).Parse([]byte("inline $a_b * c_d$ and\n\n$$\nx_1 * y_2\n$$\n"))
+	if warnings := lintMarkdown(doc); len(warnings) != 0 {
+		t.Errorf("lintMarkdown warned on math: %q", warnings)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// globCache holds compiled globs, looked up while pages render concurrently.
+var (
+	globCache   = make(map[string]*regexp.Regexp)
+	globCacheMu sync.Mutex
+)
+
+// globRegexp compiles a path glob where * matches within a segment, ** matches
+// across segments and a trailing / matches everything below a directory.
+func globRegexp(pattern string) *regexp.Regexp {
+	globCacheMu.Lock()
+	defer globCacheMu.Unlock()
+
+	if re, ok := globCache[pattern]; ok {
+		return re
+	}
+
+	glob := pattern
+	if strings.HasSuffix(glob, "/") {
+		glob += "**"
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+
+	re := regexp.MustCompile(expr.String())
+	globCache[pattern] = re
+
+	return re
+}
+
+// matchesAny reports whether the site relative url matches any of the globs.
+func matchesAny(patterns []string, url string) bool {
+	for _, pattern := range patterns {
+		if globRegexp(pattern).MatchString(url) {
+			return true
+		}
+	}
+
+	return false
+}
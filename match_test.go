@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderRawHTMLConcurrently(t *testing.T) {
+	files := make(map[string]string)
+	for i := 0; i < 32; i++ {
+		files[fmt.Sprintf("content/raw/page-%d.html", i)] = fmt.Sprintf("<p>raw {{.Title}} %d</p>", i)
+	}
+	out := newTestSite(t, files)
+	cfg.MaxConcurrency = 8
+	for i := 0; i < 8; i++ {
+		cfg.RawHTML = append(cfg.RawHTML, fmt.Sprintf("/other-%d/**", i))
+	}
+	cfg.RawHTML = append(cfg.RawHTML, "/raw/**")
+
+	globCacheMu.Lock()
+	globCache = make(map[string]*regexp.Regexp)
+	globCacheMu.Unlock()
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	for i := 0; i < 32; i++ {
+		b, err := out.ReadFile(fmt.Sprintf("public/raw/page-%d.html", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(b), "{{.Title}}") {
+			t.Errorf("page-%d.html was executed as a template:\n%s", i, b)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import "sort"
+
+// menuEntry is one item of a configured menu, nested under the entry named
+// Parent when it's set.
+type menuEntry struct {
+	Name   string
+	URL    string
+	Weight int
+	Parent string
+
+	// key identifies the entry within its menu, configured entries by their
+	// name and pages by their url, since pages can share a title.
+	key string
+}
+
+// buildMenus arranges the configured entries and the pages that add
+// themselves with menu: <name> front matter into trees, exposed to
+// templates as .Site.Menus.<name>. Pages take their title and weight and
+// can nest under another entry with menuParent: <entry name>. Pages are
+// added in path order, so pages sharing a title keep their order and
+// children nest under the same one between builds.
+func buildMenus(pages map[string]*page, menus map[string][]menuEntry) map[string][]*navNode {
+	entries := make(map[string][]menuEntry, len(menus))
+	for name, menu := range menus {
+		for _, entry := range menu {
+			entry.key = "entry " + entry.Name
+			entries[name] = append(entries[name], entry)
+		}
+	}
+
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		page := pages[key]
+		if page.Type == "" || !page.hasHTMLOutput() {
+			continue
+		}
+
+		url := pageLink(page.OutPath)
+		for _, name := range parseList(page.Meta["menu"]) {
+			entries[name] = append(entries[name], menuEntry{
+				Name:   page.Title,
+				URL:    url,
+				Weight: page.Weight,
+				Parent: page.Meta["menuparent"],
+				key:    "page " + url,
+			})
+		}
+	}
+
+	built := make(map[string][]*navNode, len(entries))
+	for name, menu := range entries {
+		built[name] = buildMenu(name, menu)
+	}
+
+	return built
+}
+
+// buildMenu nests the entries of a menu under their parents, named by the
+// first entry with that name. Configured entries sharing a name are reported
+// and the later ones left out.
+func buildMenu(name string, entries []menuEntry) []*navNode {
+	root := &navNode{}
+	nodes := make(map[string]*navNode, len(entries))
+	byName := make(map[string]string, len(entries))
+	unique := make([]menuEntry, 0, len(entries))
+	for _, entry := range entries {
+		if _, ok := nodes[entry.key]; ok {
+			if entry.key == "entry "+entry.Name {
+				report.Add("parse/menus", name, "duplicate entry %q", entry.Name)
+			}
+			continue
+		}
+
+		nodes[entry.key] = &navNode{Name: entry.Name, Title: entry.Name, URL: entry.URL, Weight: entry.Weight}
+		if _, ok := byName[entry.Name]; !ok {
+			byName[entry.Name] = entry.key
+		}
+		unique = append(unique, entry)
+	}
+
+	for _, entry := range unique {
+		parent := root
+		if entry.Parent != "" {
+			if key, ok := byName[entry.Parent]; !ok {
+				report.Add("parse/menus", name, "entry %q has unknown parent %q", entry.Name, entry.Parent)
+			} else if menuCycle(unique, byName, entry.key) {
+				report.Add("parse/menus", name, "entry %q is its own ancestor", entry.Name)
+			} else {
+				parent = nodes[key]
+			}
+		}
+
+		parent.Children = append(parent.Children, nodes[entry.key])
+	}
+
+	sortNavTree(root)
+
+	return root.Children
+}
+
+// menuCycle reports whether following the parents up from the entry with
+// key leads back to it.
+func menuCycle(entries []menuEntry, byName map[string]string, key string) bool {
+	parents := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		parents[entry.key] = byName[entry.Parent]
+	}
+
+	for current, steps := parents[key], 0; current != "" && steps <= len(entries); current, steps = parents[current], steps+1 {
+		if current == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestMenuPagesSharingATitle(t *testing.T) {
+	cfg = defaultConfig()
+	report.Reset()
+
+	pages := make(map[string]*page)
+	for _, dir := range []string{"v1", "v2", "v3"} {
+		out := filepath.Join("public", dir, "install.html")
+		pages[out] = &page{
+			Type:    "MD",
+			Title:   "Install",
+			OutPath: out,
+			Meta:    map[string]string{"menu": "main"},
+		}
+	}
+	menus := map[string][]menuEntry{
+		"main": {{Name: "Docs", URL: "/docs/"}, {Name: "Docs", URL: "/other/"}},
+	}
+
+	for i := 0; i < 10; i++ {
+		main := buildMenus(pages, menus)["main"]
+
+		var urls []string
+		for _, node := range main {
+			urls = append(urls, node.URL)
+		}
+		want := []string{"/docs/", "/v1/install.html", "/v2/install.html", "/v3/install.html"}
+		if len(urls) != len(want) {
+			t.Fatalf("menu has %v, want %v", urls, want)
+		}
+		for j := range want {
+			if urls[j] != want[j] {
+				t.Fatalf("menu has %v, want %v", urls, want)
+			}
+		}
+	}
+
+	for _, problem := range report.Problems {
+		if problem.Message != `duplicate entry "Docs"` {
+			t.Errorf("reported %s", problem)
+		}
+	}
+	if len(report.Problems) != 10 {
+		t.Errorf("got %d problems, want one per build for the configured duplicate", len(report.Problems))
+	}
+}
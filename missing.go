@@ -0,0 +1,24 @@
+package main
+
+import (
+	"sync/atomic"
+)
+
+// missingReferences counts the missing includes and assets reported under
+// OnMissing fail, which fails the build once it's finished.
+var missingReferences atomic.Int64
+
+// reportMissing reports a page referencing an include or asset that doesn't
+// exist, as OnMissing sets. It returns whether the reference should be
+// omitted from the page rather than left as written.
+func reportMissing(phase, path, format string, args ...interface{}) bool {
+	switch cfg.OnMissing {
+	case "omit":
+		return true
+	case "fail":
+		missingReferences.Add(1)
+	}
+
+	report.Add(phase, path, format, args...)
+	return false
+}
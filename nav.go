@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+type navNode struct {
+	Name     string
+	Title    string
+	URL      string
+	Weight   int
+	Children []*navNode
+}
+
+// buildNavTree arranges every visible html page into a tree following the
+// output directory structure, with index pages standing in for their section.
+// Hidden pages are left out but still render and can be linked to.
+func buildNavTree(pages map[string]*page) *navNode {
+	root := &navNode{Name: "", URL: "/"}
+	sections := map[string]*navNode{"": root}
+
+	var section func(dir string) *navNode
+	section = func(dir string) *navNode {
+		if node, ok := sections[dir]; ok {
+			return node
+		}
+
+		parent := path.Dir(dir)
+		if parent == "." {
+			parent = ""
+		}
+
+		node := &navNode{Name: path.Base(dir), Title: displayName(path.Base(dir))}
+		sections[dir] = node
+		section(parent).Children = append(section(parent).Children, node)
+
+		return node
+	}
+
+	for _, page := range pages {
+		if page.Type == "" || page.Hidden || !page.hasHTMLOutput() {
+			continue
+		}
+
+		url := pageURL(page.OutPath)
+		dir := strings.TrimPrefix(path.Dir(url), "/")
+
+		if path.Base(url) == "index.html" {
+			node := section(dir)
+			node.Title = page.Title
+			node.URL = pageLink(page.OutPath)
+			node.Weight = page.Weight
+			continue
+		}
+
+		section(dir).Children = append(section(dir).Children, &navNode{
+			Name:   strings.TrimSuffix(path.Base(url), path.Ext(url)),
+			Title:  page.Title,
+			URL:    pageLink(page.OutPath),
+			Weight: page.Weight,
+		})
+	}
+
+	sortNavTree(root)
+
+	return root
+}
+
+func sortNavTree(node *navNode) {
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		if node.Children[i].Weight != node.Children[j].Weight {
+			return node.Children[i].Weight < node.Children[j].Weight
+		}
+		return strings.ToLower(node.Children[i].Title) < strings.ToLower(node.Children[j].Title)
+	})
+
+	for _, child := range node.Children {
+		sortNavTree(child)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import "testing"
+
+func TestNestedSectionTitles(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/index.md":                                          "# Home\n",
+		"content/user_guide/index.md":                               "# Guide\n",
+		"content/user_guide/getting-started/index.md":               "# Start\n",
+		"content/user_guide/getting-started/first_steps/index.md":   "---\ntitle: Your First Steps\n---\n# Steps\n",
+		"content/user_guide/getting-started/first_steps/install.md": "# Install\n",
+		"content/user_guide/getting-started/deep_dive/notes.md":     "# Notes\n",
+	})
+	cfg.TitleCase = true
+
+	err := parseDirectoryContent("content", "gen")
+	if err != nil {
+		t.Fatalf("parseDirectoryContent: %s", err)
+	}
+
+	titles := map[string]string{
+		"public/index.html":                                          "Gen",
+		"public/user_guide/index.html":                               "User Guide",
+		"public/user_guide/getting-started/index.html":               "Getting Started",
+		"public/user_guide/getting-started/first_steps/index.html":   "Your First Steps",
+		"public/user_guide/getting-started/first_steps/install.html": "Install",
+	}
+	for key, want := range titles {
+		p, ok := pages[key]
+		if !ok {
+			t.Errorf("no page for %s", key)
+			continue
+		}
+		if p.Title != want {
+			t.Errorf("%s has title %q, want %q", key, p.Title, want)
+		}
+	}
+
+	root := buildNavTree(pages)
+	guide := navChild(t, root, "user_guide")
+	start := navChild(t, guide, "getting-started")
+	tests := []struct {
+		name  string
+		title string
+	}{
+		{"first_steps", "Your First Steps"},
+		{"deep_dive", "Deep Dive"},
+	}
+	for _, tt := range tests {
+		if node := navChild(t, start, tt.name); node != nil && node.Title != tt.title {
+			t.Errorf("section %s has title %q, want %q", tt.name, node.Title, tt.title)
+		}
+	}
+	if guide != nil && guide.Title != "User Guide" {
+		t.Errorf("section user_guide has title %q, want %q", guide.Title, "User Guide")
+	}
+}
+
+// navChild returns the child of node named name, failing the test when
+// there's none.
+func navChild(t *testing.T, node *navNode, name string) *navNode {
+	t.Helper()
+
+	if node == nil {
+		return nil
+	}
+	for _, child := range node.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+
+	t.Errorf("no section %s below %q", name, node.Name)
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+const archetypesDirectory = "archetypes"
+
+// defaultArchetype scaffolds pages when the project doesn't have an
+// archetype for the section or an archetypes/default.md.
+const defaultArchetype = `---
+title: {{.Title}}
+date: {{.Date}}
+draft: true
+---
+`
+
+// archetype is what an archetype template renders with.
+type archetype struct {
+	Title   string
+	Date    string
+	Name    string
+	Section string
+	Path    string
+}
+
+// newContent scaffolds a markdown page in content from name, like
+// post/my-title, rendering the archetype for its section,
+// archetypes/post.md, or archetypes/default.md. Existing files are never
+// overwritten.
+func newContent(name string) error {
+	if cfg.ContentArchive != "" {
+		return fmt.Errorf("[gen/new] content is read from %s, unable to add pages to it", cfg.ContentArchive)
+	}
+
+	name = path.Clean(strings.TrimPrefix(filepath.ToSlash(name), "content/"))
+	if !fs.ValidPath(name) || name == "." {
+		return fmt.Errorf("[gen/new] %s isn't a path in content", name)
+	}
+	if !isMarkdown(name) {
+		name += ".md"
+	}
+
+	loc, err := loadLocation(cfg.Timezone)
+	if err != nil {
+		return err
+	}
+
+	section := ""
+	if dir := path.Dir(name); dir != "." {
+		section = strings.SplitN(dir, "/", 2)[0]
+	}
+	base := strings.TrimSuffix(path.Base(name), path.Ext(name))
+
+	dateFormat := time.RFC3339
+	if len(cfg.DateFormats) > 0 {
+		dateFormat = cfg.DateFormats[0]
+	}
+
+	values := archetype{
+		Title:   displayName(strings.NewReplacer("-", " ", "_", " ").Replace(base)),
+		Date:    time.Now().In(loc).Format(dateFormat),
+		Name:    base,
+		Section: section,
+		Path:    name,
+	}
+
+	source, archetypePath, err := readArchetype(section)
+	if err != nil {
+		return err
+	}
+
+	t, err := texttemplate.New(archetypePath).Parse(source)
+	if err != nil {
+		return fmt.Errorf("[gen/new] unable to parse archetype %s: %s", archetypePath, err)
+	}
+
+	var b bytes.Buffer
+	err = t.Execute(&b, values)
+	if err != nil {
+		return fmt.Errorf("[gen/new] unable to render archetype %s: %s", archetypePath, err)
+	}
+
+	outPath := filepath.Join("content", filepath.FromSlash(name))
+	err = os.MkdirAll(filepath.Dir(outPath), 0755)
+	if err != nil {
+		return fmt.Errorf("[gen/new] unable to create directory for %s: %s", outPath, err)
+	}
+
+	f, err := os.OpenFile(outPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("[gen/new] %s already exists", outPath)
+	}
+	if err != nil {
+		return fmt.Errorf("[gen/new] unable to create %s: %s", outPath, err)
+	}
+
+	_, err = f.Write(b.Bytes())
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("[gen/new] unable to write %s: %s", outPath, err)
+	}
+
+	log.Printf("[gen/new] created %s from %s", outPath, archetypePath)
+	return nil
+}
+
+// readArchetype returns the archetype for a section and where it came from,
+// falling back to default.md and then defaultArchetype.
+func readArchetype(section string) (string, string, error) {
+	candidates := []string{filepath.Join(archetypesDirectory, "default.md")}
+	if section != "" {
+		candidates = append([]string{filepath.Join(archetypesDirectory, section+".md")}, candidates...)
+	}
+
+	for _, candidate := range candidates {
+		b, err := os.ReadFile(candidate)
+		if err == nil {
+			return string(b), candidate, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", "", fmt.Errorf("[gen/new] unable to read archetype %s: %s", candidate, err)
+		}
+	}
+
+	return defaultArchetype, "the default archetype", nil
+}
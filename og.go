@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/jpeg"
+	"image/png"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	xdraw "golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	ogCacheDir     = ".cache/og"
+	ogCacheVersion = 1
+	ogWidth        = 1200
+	ogHeight       = 630
+	ogMargin       = 80
+	ogMaxLines     = 4
+)
+
+// ogTitleSizes are the font sizes tried in turn until the title fits in
+// ogMaxLines.
+var ogTitleSizes = []float64{72, 60, 48}
+
+func init() {
+	registerTransform("ogImage", func() bool { return cfg.OGImages }, addOGImage)
+}
+
+// setOGImage points the page's og:image at its image front matter or, when
+// it doesn't have one, at the card generated for it in og/.
+func (p *page) setOGImage() {
+	if !cfg.OGImages || p.Type == "" || !p.hasHTMLOutput() {
+		return
+	}
+
+	src := p.Meta["image"]
+	if src == "" {
+		src = pageURL(ogOutPath(p.OutPath))
+	}
+	if strings.HasPrefix(src, "/") {
+		src = strings.TrimSuffix(cfg.BaseURL, "/") + src
+	}
+
+	p.OGImage = src
+}
+
+// ogOutPath is where the card of a page is written, its output path below
+// og/ with .png in place of .html, so /docs.html and /docs/index.html get
+// og/docs.png and og/docs/index.png rather than sharing a card.
+func ogOutPath(outPath string) string {
+	rel := strings.TrimPrefix(path.Clean(pageURL(outPath)), "/")
+	rel = strings.TrimSuffix(rel, path.Ext(rel)) + ".png"
+
+	return filepath.Join("public", "og", filepath.FromSlash(rel))
+}
+
+// addOGImage adds the og:image meta tag, unless the page's template has one.
+func addOGImage(doc *html.Node, p *page) error {
+	if p.OGImage == "" {
+		return nil
+	}
+
+	head := findElement(doc, atom.Head)
+	if head != nil && findOGImage(head) {
+		return nil
+	}
+
+	return appendToHead(doc, fmt.Sprintf(`<meta property="og:image" content="%s">`, template.HTMLEscapeString(p.OGImage)))
+}
+
+func findOGImage(head *html.Node) bool {
+	found := false
+	walkElements(head, func(n *html.Node) {
+		if n.DataAtom == atom.Meta && getAttr(n, "property") == "og:image" {
+			found = true
+		}
+	})
+
+	return found
+}
+
+// ogStyle is what a card is drawn with, loaded once a build.
+type ogStyle struct {
+	font       *opentype.Font
+	background image.Image
+	fill       color.Color
+	text       color.Color
+	key        string
+}
+
+// generateOGImages draws the card of every page without image front matter,
+// reusing cards cached in .cache/og by a hash of the title and style.
+func generateOGImages(pages map[string]*page) error {
+	style, err := loadOGStyle()
+	if err != nil {
+		return err
+	}
+
+	// Cards are claimed before they're drawn concurrently, so two pages
+	// mapping to one card are reported instead of overwriting each other.
+	keys := make([]string, 0, len(pages))
+	for key := range pages {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	claimed := make(map[string]*page)
+	cards := make(map[string]*page)
+	for _, key := range keys {
+		p := pages[key]
+		if p.OGImage == "" || p.Meta["image"] != "" {
+			continue
+		}
+
+		outPath := ogOutPath(p.OutPath)
+		if existing, ok := claimed[outPath]; ok {
+			report.Add("render/og", p.Path, "%s and %s both generate %s, keeping %s", existing.Path, p.Path, outPath, existing.Path)
+			continue
+		}
+
+		claimed[outPath] = p
+		cards[p.OutPath] = p
+	}
+
+	forEachPage(cards, func(p *page) {
+		outPath := ogOutPath(p.OutPath)
+		err := output.MkdirAll(filepath.Dir(outPath))
+		if err != nil {
+			log.Printf("[gen/render/og] unable to create directory for %s: %s", outPath, err)
+			return
+		}
+
+		card, err := style.card(p.Title)
+		if err == nil {
+			err = output.WriteFile(outPath, card)
+		}
+		if err != nil {
+			log.Printf("[gen/render/og] unable to write %s for %s: %s", outPath, p.Path, err)
+			return
+		}
+
+		log.Printf("[gen/render/og] rendered file %s", outPath)
+	})
+
+	return nil
+}
+
+func loadOGStyle() (*ogStyle, error) {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00%s\x00", ogCacheVersion, cfg.Title, cfg.OGTextColor)
+
+	fontData := gobold.TTF
+	if cfg.OGFont != "" {
+		b, err := os.ReadFile(cfg.OGFont)
+		if err != nil {
+			return nil, fmt.Errorf("[gen/init/og] unable to read font %s: %s", cfg.OGFont, err)
+		}
+		fontData = b
+	}
+	h.Write(fontData)
+
+	f, err := opentype.Parse(fontData)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/og] unable to parse font %s: %s", cfg.OGFont, err)
+	}
+
+	text, err := parseHexColor(cfg.OGTextColor)
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/og] invalid OGTextColor: %s", err)
+	}
+
+	style := &ogStyle{font: f, text: text}
+	if strings.HasPrefix(cfg.OGBackground, "#") {
+		style.fill, err = parseHexColor(cfg.OGBackground)
+		if err != nil {
+			return nil, fmt.Errorf("[gen/init/og] invalid OGBackground: %s", err)
+		}
+		h.Write([]byte(cfg.OGBackground))
+	} else {
+		b, err := os.ReadFile(cfg.OGBackground)
+		if err != nil {
+			return nil, fmt.Errorf("[gen/init/og] unable to read background %s: %s", cfg.OGBackground, err)
+		}
+
+		style.background, _, err = image.Decode(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("[gen/init/og] unable to decode background %s: %s", cfg.OGBackground, err)
+		}
+		h.Write(b)
+	}
+
+	style.key = hex.EncodeToString(h.Sum(nil))
+	return style, nil
+}
+
+// card returns the png of a card for title, from the cache when it's been
+// drawn before.
+func (s *ogStyle) card(title string) ([]byte, error) {
+	sum := sha256.Sum256([]byte(s.key + "\x00" + title))
+	cachePath := filepath.Join(ogCacheDir, hex.EncodeToString(sum[:])+".png")
+	if cached, err := os.ReadFile(cachePath); err == nil {
+		return cached, nil
+	}
+
+	img, err := s.draw(title)
+	if err != nil {
+		return nil, err
+	}
+
+	var encoded bytes.Buffer
+	err = png.Encode(&encoded, img)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeCacheEntry(ogCacheDir, cachePath, encoded.Bytes())
+	if err != nil {
+		log.Printf("[gen/cache/og] unable to write cache entry %s: %s", cachePath, err)
+	}
+
+	return encoded.Bytes(), nil
+}
+
+// draw lays the title out over the background, wrapped at the margins in the
+// largest size that fits, with the site title along the bottom.
+func (s *ogStyle) draw(title string) (image.Image, error) {
+	img := image.NewRGBA(image.Rect(0, 0, ogWidth, ogHeight))
+	if s.background != nil {
+		xdraw.ApproxBiLinear.Scale(img, img.Bounds(), s.background, s.background.Bounds(), draw.Src, nil)
+	} else {
+		draw.Draw(img, img.Bounds(), image.NewUniform(s.fill), image.Point{}, draw.Src)
+	}
+
+	var face font.Face
+	var lines []string
+	for _, size := range ogTitleSizes {
+		var err error
+		face, err = opentype.NewFace(s.font, &opentype.FaceOptions{Size: size, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+
+		lines = wrapText(face, title, ogWidth-2*ogMargin)
+		if len(lines) <= ogMaxLines {
+			break
+		}
+	}
+	if len(lines) > ogMaxLines {
+		lines = lines[:ogMaxLines]
+		lines[ogMaxLines-1] += "…"
+	}
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(s.text), Face: face}
+	lineHeight := face.Metrics().Height.Ceil() * 6 / 5
+	y := (ogHeight-len(lines)*lineHeight)/2 + face.Metrics().Ascent.Ceil()
+	for _, line := range lines {
+		d.Dot = fixed.P(ogMargin, y)
+		d.DrawString(line)
+		y += lineHeight
+	}
+
+	if cfg.Title != "" {
+		small, err := opentype.NewFace(s.font, &opentype.FaceOptions{Size: 32, DPI: 72, Hinting: font.HintingFull})
+		if err != nil {
+			return nil, err
+		}
+
+		d.Face = small
+		d.Dot = fixed.P(ogMargin, ogHeight-ogMargin/2-small.Metrics().Descent.Ceil())
+		d.DrawString(cfg.Title)
+	}
+
+	return img, nil
+}
+
+// wrapText breaks text into lines no wider than width, a word longer than
+// the width gets a line to itself.
+func wrapText(face font.Face, text string, width int) []string {
+	lines := make([]string, 0)
+	line := ""
+	for _, word := range strings.Fields(text) {
+		candidate := strings.TrimSpace(line + " " + word)
+		if line != "" && font.MeasureString(face, candidate).Ceil() > width {
+			lines = append(lines, line)
+			candidate = word
+		}
+		line = candidate
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// parseHexColor reads #rgb and #rrggbb colours.
+func parseHexColor(s string) (color.Color, error) {
+	hexDigits := strings.TrimPrefix(s, "#")
+	if len(hexDigits) == 3 {
+		hexDigits = string([]byte{hexDigits[0], hexDigits[0], hexDigits[1], hexDigits[1], hexDigits[2], hexDigits[2]})
+	}
+
+	v, err := strconv.ParseUint(hexDigits, 16, 32)
+	if err != nil || len(hexDigits) != 6 || !strings.HasPrefix(s, "#") {
+		return nil, fmt.Errorf("%q isn't a colour like #1f2937", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
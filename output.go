@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// outputFS is where the build writes its output, either the public directory
+// on disk or an in memory filesystem.
+type outputFS interface {
+	Create(path string) (io.WriteCloser, error)
+	WriteFile(path string, data []byte) error
+	MkdirAll(path string) error
+	ReadFile(path string) ([]byte, error)
+	Exists(path string) bool
+	Stat(path string) (fs.FileInfo, error)
+
+	// SetInfo sets the permissions and modification time of a file.
+	SetInfo(path string, mode fs.FileMode, modTime time.Time) error
+
+	// Keep marks an existing file as part of this build without rewriting it.
+	Keep(path string) error
+
+	// Link makes path a hard or symbolic link to target, replacing anything
+	// at path, and Remove deletes a file if it exists.
+	Link(target, path string, symbolic bool) error
+	Remove(path string) error
+}
+
+var output outputFS = diskOutput{}
+
+// newDiskOutput writes to disk, retrying failed writes when IORetries is set.
+func newDiskOutput() outputFS {
+	if cfg.IORetries > 0 {
+		return retryingOutput{diskOutput{}}
+	}
+
+	return diskOutput{}
+}
+
+type diskOutput struct{}
+
+func (diskOutput) Create(path string) (io.WriteCloser, error) {
+	return os.Create(path)
+}
+
+func (diskOutput) WriteFile(path string, data []byte) error {
+	return os.WriteFile(path, data, 0600)
+}
+
+func (diskOutput) MkdirAll(path string) error {
+	return os.MkdirAll(path, 0700)
+}
+
+func (diskOutput) ReadFile(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+func (diskOutput) Exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func (diskOutput) Stat(path string) (fs.FileInfo, error) {
+	return os.Stat(path)
+}
+
+func (diskOutput) SetInfo(path string, mode fs.FileMode, modTime time.Time) error {
+	err := os.Chmod(path, mode)
+	if err != nil {
+		return err
+	}
+
+	return os.Chtimes(path, modTime, modTime)
+}
+
+func (diskOutput) Keep(path string) error {
+	return nil
+}
+
+func (d diskOutput) Link(target, path string, symbolic bool) error {
+	err := d.Remove(path)
+	if err != nil {
+		return err
+	}
+
+	if !symbolic {
+		return os.Link(target, path)
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(path), target)
+	if err != nil {
+		return err
+	}
+
+	return os.Symlink(rel, path)
+}
+
+func (diskOutput) Remove(path string) error {
+	err := os.Remove(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// memoryOutput collects the build output in memory, keyed by the slash
+// separated path relative to public.
+type memoryOutput struct {
+	mu    sync.Mutex
+	Files mapFS
+}
+
+func newMemoryOutput() *memoryOutput {
+	return &memoryOutput{Files: make(mapFS)}
+}
+
+func (m *memoryOutput) key(path string) string {
+	key := strings.TrimPrefix(filepath.ToSlash(filepath.Clean(path)), "public")
+	return strings.TrimPrefix(key, "/")
+}
+
+func (m *memoryOutput) Create(path string) (io.WriteCloser, error) {
+	return &memoryFile{output: m, path: path}, nil
+}
+
+func (m *memoryOutput) WriteFile(path string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.Files[m.key(path)] = &mapFile{
+		Data:    append([]byte(nil), data...),
+		Mode:    0600,
+		ModTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (m *memoryOutput) MkdirAll(path string) error {
+	key := m.key(path)
+	if key == "" {
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.Files[key]; !ok {
+		m.Files[key] = &mapFile{Mode: fs.ModeDir | 0700, ModTime: time.Now()}
+	}
+
+	return nil
+}
+
+func (m *memoryOutput) ReadFile(path string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.Files[m.key(path)]
+	if !ok || f.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: path, Err: fs.ErrNotExist}
+	}
+
+	return f.Data, nil
+}
+
+func (m *memoryOutput) Exists(path string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.Files[m.key(path)]
+	return ok
+}
+
+func (m *memoryOutput) Stat(path string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := m.key(path)
+	if key == "" {
+		key = "."
+	}
+
+	return fs.Stat(m.Files, key)
+}
+
+func (m *memoryOutput) SetInfo(path string, mode fs.FileMode, modTime time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.Files[m.key(path)]
+	if !ok {
+		return &fs.PathError{Op: "chmod", Path: path, Err: fs.ErrNotExist}
+	}
+
+	f.Mode = f.Mode&fs.ModeType | mode
+	f.ModTime = modTime
+	return nil
+}
+
+func (m *memoryOutput) Keep(path string) error {
+	return nil
+}
+
+// Link shares the target's data, links of either kind behave the same in
+// memory.
+func (m *memoryOutput) Link(target, path string, symbolic bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, ok := m.Files[m.key(target)]
+	if !ok || f.Mode.IsDir() {
+		return &fs.PathError{Op: "link", Path: target, Err: fs.ErrNotExist}
+	}
+
+	linked := *f
+	m.Files[m.key(path)] = &linked
+	return nil
+}
+
+func (m *memoryOutput) Remove(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.Files, m.key(path))
+	return nil
+}
+
+// memoryFile buffers writes until it's closed.
+type memoryFile struct {
+	bytes.Buffer
+	output *memoryOutput
+	path   string
+}
+
+func (f *memoryFile) Close() error {
+	return f.output.WriteFile(f.path, f.Bytes())
+}
+
+// buildInMemory runs a full build writing to memory instead of public,
+// returning the generated files.
+func buildInMemory() (mapFS, error) {
+	out := newMemoryOutput()
+
+	err := build(out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Files, nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+var (
+	outputTemplates   = make(map[string]*texttemplate.Template)
+	outputTemplatesMu sync.Mutex
+)
+
+var outputFuncs = texttemplate.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// hasHTMLOutput reports whether the page renders to html, pages only emitting
+// other formats are left out of backlinks and the sitemap.
+func (p *page) hasHTMLOutput() bool {
+	if len(p.Outputs) == 0 {
+		return true
+	}
+
+	for _, format := range p.Outputs {
+		if format == "html" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// outputTemplate loads and caches output.<format> in TemplateDir for non
+// html output formats.
+func outputTemplate(format string) (*texttemplate.Template, error) {
+	outputTemplatesMu.Lock()
+	defer outputTemplatesMu.Unlock()
+
+	if t, ok := outputTemplates[format]; ok {
+		return t, nil
+	}
+
+	path := templatePath("output." + format)
+	t, err := texttemplate.New(filepath.Base(path)).Funcs(outputFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	outputTemplates[format] = t
+	return t, nil
+}
+
+func renderOutput(p page, format string) {
+	outPath := strings.TrimSuffix(p.OutPath, filepath.Ext(p.OutPath)) + "." + format
+
+	t, err := outputTemplate(format)
+	if err != nil {
+		log.Printf("[gen/render/output] unable to open %s output template: %s", format, err)
+		return
+	}
+
+	f, err := output.Create(outPath)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", outPath, err)
+		return
+	}
+	defer f.Close()
+
+	err = t.Execute(f, p)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to render to file %s: %s", outPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", outPath)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// slugOutPath replaces the file name of outPath with the slug front matter
+// value, keeping the directory and extension. The slug can't leave the
+// directory, and is sanitized like the rest of the output path.
+func slugOutPath(outPath, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return outPath, nil
+	}
+
+	if strings.ContainsAny(value, `/\`) || value == "." || value == ".." {
+		return outPath, fmt.Errorf("slug %q must be a file name without a directory", value)
+	}
+
+	ext := filepath.Ext(outPath)
+	if strings.EqualFold(filepath.Ext(value), ext) {
+		value = strings.TrimSuffix(value, filepath.Ext(value))
+	}
+
+	if strings.TrimLeft(value, ".") == "" {
+		return outPath, fmt.Errorf("slug %q doesn't have a name", value)
+	}
+
+	return filepath.Join(filepath.Dir(outPath), sanitizeSegment(value+ext)), nil
+}
+
+// applySlug renames the page's output file from its slug front matter.
+func (p *page) applySlug() {
+	outPath, err := slugOutPath(p.OutPath, p.Meta["slug"])
+	if err != nil {
+		report.Add("validate/slug", p.Path, "%s", err)
+		return
+	}
+
+	p.OutPath = outPath
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"html/template"
+)
+
+// PreProcess rewrites a markdown source before it's converted, like
+// expanding includes or macros.
+type PreProcess func(path string, md []byte) []byte
+
+// Convert turns a preprocessed markdown source into html.
+type Convert func(md []byte, options markdownOptions) (template.HTML, markdownInfo)
+
+// PostProcess rewrites the html converted from a markdown source, before
+// it's placed in a template. Transforms of whole rendered pages come later,
+// see registerTransform.
+type PostProcess func(path string, content template.HTML) template.HTML
+
+type preProcessStage struct {
+	Name    string
+	Process PreProcess
+}
+
+type postProcessStage struct {
+	Name    string
+	Process PostProcess
+}
+
+// markdownPipeline is the stages a markdown page goes through, run in the
+// order they're listed. New stages are added to the list rather than called
+// from parseFile, so the order they compose in stays in one place.
+type markdownPipeline struct {
+	PreProcess  []preProcessStage
+	Convert     Convert
+	PostProcess []postProcessStage
+}
+
+var pagePipeline = markdownPipeline{
+	PreProcess: []preProcessStage{
+		{"includes", expandIncludes},
+	},
+	Convert: cachedMarkdown2html,
+	PostProcess: []postProcessStage{
+		{"anchors", dedupeIDs},
+		{"sourceLinks", rewriteSourceLinks},
+	},
+}
+
+// run sends a source through every stage, returning the preprocessed source,
+// which summaries are cut from, with the html and what the conversion found.
+func (pl markdownPipeline) run(path string, md []byte, options markdownOptions) ([]byte, template.HTML, markdownInfo) {
+	for _, stage := range pl.PreProcess {
+		md = stage.Process(path, md)
+	}
+
+	content, info := pl.Convert(md, options)
+
+	return md, pl.postProcess(path, content), info
+}
+
+// postProcess runs only the PostProcess stages, for html converted outside
+// the pipeline like the output of Processors.
+func (pl markdownPipeline) postProcess(path string, content template.HTML) template.HTML {
+	for _, stage := range pl.PostProcess {
+		content = stage.Process(path, content)
+	}
+
+	return content
+}
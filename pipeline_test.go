@@ -0,0 +1,88 @@
+package main
+
+import (
+	"html/template"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/parser"
+)
+
+func TestMarkdownPipelineRunsStagesInOrder(t *testing.T) {
+	var calls []string
+	pre := func(name string) preProcessStage {
+		return preProcessStage{name, func(path string, md []byte) []byte {
+			calls = append(calls, name)
+			return append(md, " "+name...)
+		}}
+	}
+	post := func(name string) postProcessStage {
+		return postProcessStage{name, func(path string, content template.HTML) template.HTML {
+			calls = append(calls, name)
+			return content + template.HTML(" "+name)
+		}}
+	}
+
+	pl := markdownPipeline{
+		PreProcess: []preProcessStage{pre("macros"), pre("includes")},
+		Convert: func(md []byte, options markdownOptions) (template.HTML, markdownInfo) {
+			calls = append(calls, "convert")
+			return template.HTML("<p>" + string(md) + "</p>"), markdownInfo{}
+		},
+		PostProcess: []postProcessStage{post("anchors"), post("links")},
+	}
+
+	md, content, _ := pl.run("content/page.md", []byte("source"), markdownOptions{})
+
+	if want := []string{"macros", "includes", "convert", "anchors", "links"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("ran stages %v, want %v", calls, want)
+	}
+	if want := "source macros includes"; string(md) != want {
+		t.Errorf("preprocessed source is %q, want %q", md, want)
+	}
+	if want := template.HTML("<p>source macros includes</p> anchors links"); content != want {
+		t.Errorf("content is %q, want %q", content, want)
+	}
+
+	calls = nil
+	content = pl.postProcess("content/page.md", "<p>processed</p>")
+
+	if want := []string{"anchors", "links"}; !reflect.DeepEqual(calls, want) {
+		t.Errorf("postProcess ran stages %v, want %v", calls, want)
+	}
+	if want := template.HTML("<p>processed</p> anchors links"); content != want {
+		t.Errorf("postProcess content is %q, want %q", content, want)
+	}
+}
+
+func TestPagePipeline(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"includes/usage.md": "## Usage\n\nRun it.\n",
+		"content/other.md":  "# Other\n",
+	})
+
+	source := "## Usage\n\n{{include \"usage\"}}\n\nSee [the other page](other.md).\n"
+	options := siteMarkdownOptions()
+	options.Extensions |= parser.AutoHeadingIDs
+	md, content, _ := pagePipeline.run("content/page.md", []byte(source), options)
+
+	if strings.Contains(string(md), "include") || !strings.Contains(string(md), "Run it.") {
+		t.Errorf("include wasn't expanded before returning the source: %q", md)
+	}
+
+	html := string(content)
+	for _, want := range []string{
+		// The included markdown is converted with the page.
+		"<p>Run it.</p>",
+		// Anchors are deduplicated after conversion, across included headings.
+		`id="usage"`,
+		`id="usage-1"`,
+		// Links to sources point at their output.
+		`href="/other.html"`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("content is missing %s:\n%s", want, html)
+		}
+	}
+}
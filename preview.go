@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const previewDirectory = "preview"
+
+// rootedOutput moves everything written under public to another directory,
+// so a preview build mirrors the production paths without touching public.
+type rootedOutput struct {
+	out  outputFS
+	root string
+}
+
+func (r rootedOutput) path(path string) string {
+	clean := filepath.Clean(path)
+	if clean == "public" {
+		return r.root
+	}
+
+	if rest := strings.TrimPrefix(clean, "public"+string(filepath.Separator)); rest != clean {
+		return filepath.Join(r.root, rest)
+	}
+
+	return path
+}
+
+func (r rootedOutput) Create(path string) (io.WriteCloser, error) {
+	return r.out.Create(r.path(path))
+}
+
+func (r rootedOutput) WriteFile(path string, data []byte) error {
+	return r.out.WriteFile(r.path(path), data)
+}
+
+func (r rootedOutput) MkdirAll(path string) error {
+	return r.out.MkdirAll(r.path(path))
+}
+
+func (r rootedOutput) ReadFile(path string) ([]byte, error) {
+	return r.out.ReadFile(r.path(path))
+}
+
+func (r rootedOutput) Exists(path string) bool {
+	return r.out.Exists(r.path(path))
+}
+
+func (r rootedOutput) Stat(path string) (fs.FileInfo, error) {
+	return r.out.Stat(r.path(path))
+}
+
+func (r rootedOutput) SetInfo(path string, mode fs.FileMode, modTime time.Time) error {
+	return r.out.SetInfo(r.path(path), mode, modTime)
+}
+
+func (r rootedOutput) Keep(path string) error {
+	return r.out.Keep(r.path(path))
+}
+
+func (r rootedOutput) Link(target, path string, symbolic bool) error {
+	return r.out.Link(r.path(target), r.path(path), symbolic)
+}
+
+func (r rootedOutput) Remove(path string) error {
+	return r.out.Remove(r.path(path))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// processor returns the command configured in Processors for the source's
+// extension, matched without regard to case.
+func processor(path string) (string, bool) {
+	ext := filepath.Ext(path)
+	for processorExt, command := range cfg.Processors {
+		if strings.EqualFold(ext, processorExt) {
+			return command, true
+		}
+	}
+
+	return "", false
+}
+
+// runProcessor pipes a source through its processor's shell command,
+// returning the html it writes to stdout. The command gets the source's path
+// in GEN_SOURCE for converters that need it, and fails with its stderr.
+func runProcessor(path, command string, source []byte) (template.HTML, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = bytes.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = append(os.Environ(), "GEN_SOURCE="+path)
+
+	err := cmd.Run()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("processor for %s failed: %s: %s", filepath.Ext(path), err, message)
+		}
+		return "", fmt.Errorf("processor for %s failed: %s", filepath.Ext(path), err)
+	}
+
+	return template.HTML(stdout.String()), nil
+}
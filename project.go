@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// enterProject makes dir the working directory so content, templates,
+// gen.json and public resolve against the project wherever gen is run from.
+// The -render path is taken relative to where gen was run.
+func enterProject(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return fmt.Errorf("[gen/init/project] unable to resolve project %s: %s", dir, err)
+	}
+
+	if *flagRender != "" {
+		render, err := filepath.Abs(*flagRender)
+		if err != nil {
+			return fmt.Errorf("[gen/init/project] unable to resolve %s: %s", *flagRender, err)
+		}
+
+		render, err = filepath.Rel(root, render)
+		if err != nil {
+			return fmt.Errorf("[gen/init/project] %s isn't in the project: %s", *flagRender, err)
+		}
+		*flagRender = render
+	}
+
+	err = os.Chdir(root)
+	if err != nil {
+		return fmt.Errorf("[gen/init/project] unable to enter project %s: %s", dir, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"html/template"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// recentTemplate renders the recently updated page, loaded from
+// RecentTemplate when it exists.
+var recentTemplate *template.Template
+
+// generateRecent returns the Recent most recently modified pages, newest
+// first, by their source modification time or with RecentFromGit the time of
+// the last commit touching them.
+func generateRecent(pages map[string]*page) []*page {
+	selected := make([]*page, 0)
+	for _, page := range pages {
+		if page.Type == "" || page.Draft || !page.hasHTMLOutput() {
+			continue
+		}
+
+		if cfg.RecentFromGit {
+			if commit := lastCommit(page.Path); commit != nil {
+				page.ModTime = commit.Date
+			}
+		}
+
+		if !page.ModTime.IsZero() {
+			selected = append(selected, page)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if selected[i].ModTime.Equal(selected[j].ModTime) {
+			return selected[i].OutPath < selected[j].OutPath
+		}
+		return selected[i].ModTime.After(selected[j].ModTime)
+	})
+
+	if len(selected) > cfg.Recent {
+		selected = selected[:cfg.Recent]
+	}
+
+	return selected
+}
+
+// renderRecent writes the recently updated page to RecentPath and, with
+// RecentFeed, an Atom feed of the same pages beside it.
+func renderRecent(pages map[string]*page) error {
+	selected := generateRecent(pages)
+
+	if recentTemplate != nil {
+		recent, err := NewPage(filepath.Join("content", "recent", "index.html"), cfg.RecentPath, "Recent")
+		if err != nil {
+			return err
+		}
+
+		recent.Recent = make([]archiveEntry, 0, len(selected))
+		for _, page := range selected {
+			recent.Recent = append(recent.Recent, archiveEntry{
+				URL:   pageLink(page.OutPath),
+				Title: page.Title,
+				Date:  page.ModTime,
+			})
+		}
+
+		renderRecentPage(recent)
+	}
+
+	if cfg.RecentFeed {
+		dir := "/" + strings.TrimPrefix(pageURL(filepath.Dir(cfg.RecentPath)), "/")
+		writeXML(filepath.Join(filepath.Dir(cfg.RecentPath), "atom.xml"), generateRecentFeed(dir, selected))
+	}
+
+	return nil
+}
+
+func renderRecentPage(p page) {
+	err := output.MkdirAll(filepath.Dir(p.OutPath))
+	if err != nil {
+		log.Printf("[gen/render/recent] unable to create directory for %s: %s", p.OutPath, err)
+		return
+	}
+
+	f, err := output.Create(p.OutPath)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", p.OutPath, err)
+		return
+	}
+	defer f.Close()
+
+	err = recentTemplate.Execute(f, p)
+	if err != nil {
+		log.Printf("[gen/render/file] unable to render to file %s: %s", p.OutPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", p.OutPath)
+}
+
+// generateRecentFeed lists the recently updated pages as an Atom feed, each
+// entry updated at its modification time.
+func generateRecentFeed(dir string, pages []*page) atomFeed {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+	dir = strings.TrimSuffix(dir, "/") + "/"
+
+	feed := atomFeed{
+		Title: cfg.Title + " recently updated",
+		Links: []atomLink{
+			{Href: baseURL + dir},
+			{Href: baseURL + dir + "atom.xml", Rel: "self"},
+		},
+		ID:      baseURL + dir + "atom.xml",
+		Updated: siteContext.BuildTime.In(siteLocation).Format(time.RFC3339),
+		Author:  siteAuthor(),
+		Entries: make([]atomEntry, 0, len(pages)),
+	}
+
+	if len(pages) > 0 {
+		feed.Updated = pages[0].ModTime.Format(time.RFC3339)
+	}
+
+	for _, page := range pages {
+		link := baseURL + pageLink(page.OutPath)
+		entry := atomEntry{
+			Title:   page.Title,
+			Link:    atomLink{Href: link},
+			ID:      link,
+			Updated: page.ModTime.Format(time.RFC3339),
+		}
+
+		if page.Summary != "" {
+			entry.Summary = &atomSummary{Type: "html", Content: string(page.Summary)}
+		}
+
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return feed
+}
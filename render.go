@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+)
+
+// renderSingle renders one content file with its layout and writes the html
+// to w, skipping the directory walk, link parsing and everything written to
+// public.
+func renderSingle(path string, w io.Writer) error {
+	err := prepareBuild(newMemoryOutput())
+	if err != nil {
+		return err
+	}
+
+	_, b, err := renderSinglePage(path, *flagDrafts, func(p *page) {
+		// Without the rest of the site the navigation only has this page.
+		single := map[string]*page{p.OutPath: p}
+		siteContext.Nav = buildNavTree(single)
+		siteContext.Menus = buildMenus(single, cfg.Menus)
+		siteContext.Stats = collectStats(single)
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// renderSinglePage parses and renders one content file against the current
+// build's state, its site, templates and moved assets, into memory,
+// returning the page and its rendered html, with drafts included when drafts
+// is set. prepare, when set, is called with the page before it renders. The
+// output and -drafts of the build are put back afterwards, so a preview
+// under -serve renders like the built page without leaking into the next
+// -watch rebuild.
+func renderSinglePage(path string, drafts bool, prepare func(*page)) (*page, []byte, error) {
+	savedOutput, savedDrafts := output, *flagDrafts
+	defer func() {
+		output, *flagDrafts = savedOutput, savedDrafts
+	}()
+
+	out := newMemoryOutput()
+	output, *flagDrafts = out, drafts
+
+	parent := "gen"
+	if dir := filepath.Dir(path); filepath.Clean(dir) != "content" && dir != "." {
+		parent = filepath.Base(dir)
+	}
+
+	p := parseFile(path, outputPath(path), parent)
+	if p == nil {
+		return nil, nil, fmt.Errorf("[gen/render/single] unable to render %s, see above", path)
+	}
+
+	if p.Type == "" {
+		return nil, nil, fmt.Errorf("[gen/render/single] %s isn't a markdown or html page", path)
+	}
+
+	if !p.hasHTMLOutput() {
+		return nil, nil, fmt.Errorf("[gen/render/single] %s doesn't have an html output", path)
+	}
+
+	if prepare != nil {
+		prepare(p)
+	}
+	p.Render()
+
+	b, err := out.ReadFile(p.OutPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("[gen/render/single] %s didn't render: %s", path, err)
+	}
+
+	return p, b, nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewRendersAgainstTheBuild(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"content/docs/page.md": "# Page\n\n![Logo](/img/logo.png)\n",
+		"content/img/logo.png": "logo",
+	})
+	cfg.AssetLayout = "flatten"
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+	site, moved := siteContext, len(movedAssets)
+
+	_, b, err := renderSinglePage(filepath.Join("content", "docs", "page.md"), true, nil)
+	if err != nil {
+		t.Fatalf("renderSinglePage: %s", err)
+	}
+
+	if !strings.Contains(string(b), `src="/assets/logo.png"`) {
+		t.Errorf("the preview doesn't point at the moved asset:\n%s", b)
+	}
+
+	built, err := out.ReadFile("public/docs/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(built) != string(b) {
+		t.Errorf("the preview differs from the built page:\n%s\nwant\n%s", b, built)
+	}
+
+	if output != outputFS(out) || siteContext != site || len(movedAssets) != moved || *flagDrafts {
+		t.Errorf("the preview changed the build's state")
+	}
+}
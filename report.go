@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+type problem struct {
+	Phase   string
+	Path    string
+	Message string
+}
+
+func (p problem) String() string {
+	return fmt.Sprintf("[gen/%s] %s: %s", p.Phase, p.Path, p.Message)
+}
+
+// buildReport collects the problems found during a build so they can be
+// listed once at the end and fail the build under -strict.
+type buildReport struct {
+	Problems []problem
+
+	mu sync.Mutex
+}
+
+var report buildReport
+
+func (r *buildReport) Add(phase, path, format string, args ...interface{}) {
+	p := problem{
+		Phase:   phase,
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	}
+
+	logProblem(p)
+
+	r.mu.Lock()
+	r.Problems = append(r.Problems, p)
+	r.mu.Unlock()
+}
+
+// Reset drops the problems collected so far, for building the site again in
+// the same process.
+func (r *buildReport) Reset() {
+	r.mu.Lock()
+	r.Problems = nil
+	r.mu.Unlock()
+}
+
+func (r *buildReport) Print() {
+	if len(r.Problems) == 0 {
+		log.Printf("[gen/report] no problems found")
+		return
+	}
+
+	sort.SliceStable(r.Problems, func(i, j int) bool {
+		return r.Problems[i].Path < r.Problems[j].Path
+	})
+
+	log.Printf("[gen/report] %d problems found", len(r.Problems))
+	for _, p := range r.Problems {
+		log.Printf("[gen/report] %s", p)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"log"
+	"time"
+)
+
+// retryIO runs an io operation, retrying it up to IORetries times with
+// doubling delays from IORetryDelay when it fails with an error that might
+// be transient. Missing files, permission errors and the like fail at once.
+func retryIO(op, path string, fn func() error) error {
+	delay := time.Duration(cfg.IORetryDelay) * time.Millisecond
+
+	err := fn()
+	for attempt := 1; err != nil && attempt <= cfg.IORetries && !permanentIOError(err); attempt++ {
+		log.Printf("[gen/io/retry] %s %s failed, retrying in %s (%d/%d): %s", op, path, delay, attempt, cfg.IORetries, err)
+		time.Sleep(delay)
+		delay *= 2
+
+		err = fn()
+	}
+
+	return err
+}
+
+func permanentIOError(err error) bool {
+	for _, permanent := range []error{fs.ErrNotExist, fs.ErrExist, fs.ErrPermission, fs.ErrInvalid, fs.ErrClosed} {
+		if errors.Is(err, permanent) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryingOutput retries opening and writing whole files in the output.
+type retryingOutput struct {
+	outputFS
+}
+
+func (r retryingOutput) Create(path string) (w io.WriteCloser, err error) {
+	err = retryIO("create", path, func() error {
+		w, err = r.outputFS.Create(path)
+		return err
+	})
+
+	return w, err
+}
+
+func (r retryingOutput) WriteFile(path string, data []byte) error {
+	return retryIO("write", path, func() error {
+		return r.outputFS.WriteFile(path, data)
+	})
+}
+
+func (r retryingOutput) MkdirAll(path string) error {
+	return retryIO("mkdir", path, func() error {
+		return r.outputFS.MkdirAll(path)
+	})
+}
+
+func (r retryingOutput) ReadFile(path string) (data []byte, err error) {
+	err = retryIO("read", path, func() error {
+		data, err = r.outputFS.ReadFile(path)
+		return err
+	})
+
+	return data, err
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+type robotsRule struct {
+	UserAgent string
+	Allow     []string
+	Disallow  []string
+}
+
+// generateRobots builds a robots.txt from the configured rules, pointing
+// crawlers at the sitemap when the site has a base url.
+func generateRobots(rules []robotsRule, baseURL, sitemap string) string {
+	var robots strings.Builder
+
+	for _, rule := range rules {
+		userAgent := rule.UserAgent
+		if userAgent == "" {
+			userAgent = "*"
+		}
+		robots.WriteString("User-agent: " + userAgent + "\n")
+
+		for _, path := range rule.Allow {
+			robots.WriteString("Allow: " + path + "\n")
+		}
+
+		if len(rule.Disallow) == 0 && len(rule.Allow) == 0 {
+			robots.WriteString("Disallow:\n")
+		}
+		for _, path := range rule.Disallow {
+			robots.WriteString("Disallow: " + path + "\n")
+		}
+
+		robots.WriteString("\n")
+	}
+
+	if baseURL != "" {
+		robots.WriteString("Sitemap: " + sitemap + "\n")
+	}
+
+	return robots.String()
+}
+
+func renderRobots(sitemap string) {
+	if _, err := statSource(filepath.Join("content", "robots.txt")); !errors.Is(err, fs.ErrNotExist) {
+		log.Printf("[gen/render/robots] using content/robots.txt, skipping generation")
+		return
+	}
+
+	err := output.WriteFile(filepath.Join("public", "robots.txt"), []byte(generateRobots(cfg.RobotsRules, cfg.BaseURL, sitemap)))
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file public/robots.txt: %s", err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file public/robots.txt")
+}
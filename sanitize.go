@@ -0,0 +1,165 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var transliterations = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ą': "a",
+	'æ': "ae", 'ç': "c", 'ć': "c", 'č': "c", 'ď': "d", 'đ': "d",
+	'è': "e", 'é': "e", 'ê': "e", 'ë': "e", 'ē': "e", 'ę': "e", 'ě': "e", 'ğ': "g",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ī': "i", 'ı': "i", 'ł': "l",
+	'ñ': "n", 'ń': "n", 'ň': "n", 'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o",
+	'ø': "o", 'ō': "o", 'ő': "o", 'œ': "oe", 'ř': "r", 'ś': "s", 'š': "s", 'ş': "s",
+	'ß': "ss", 'ť': "t", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ů': "u",
+	'ű': "u", 'ý': "y", 'ÿ': "y", 'ź': "z", 'ż': "z", 'ž': "z", 'þ': "th", 'ð': "d",
+}
+
+var (
+	sanitizedLogged   = make(map[string]bool)
+	sanitizedLoggedMu sync.Mutex
+)
+
+// sanitizeSegment makes one segment of an output path safe for urls and
+// every filesystem, logging the first time each name is changed.
+func sanitizeSegment(segment string) string {
+	sanitized := slugSegment(segment)
+	if sanitized != segment {
+		sanitizedLoggedMu.Lock()
+		if !sanitizedLogged[segment] {
+			sanitizedLogged[segment] = true
+			log.Printf("[gen/process/path] renamed %q to %q in output paths", segment, sanitized)
+		}
+		sanitizedLoggedMu.Unlock()
+	}
+
+	return sanitized
+}
+
+// slugSegment is the mapping behind sanitizeSegment. The segment is NFC
+// normalized so composed and decomposed input agree, accented latin letters
+// are transliterated unless Transliterate is off, other letters and digits
+// are kept, and runs of anything else become a single _ dropped next to
+// dots and at the ends. Segments longer than MaxPathSegmentLength are cut
+// short with a hash of the normalized name so they stay unique. The mapping
+// only depends on the segment, so links to the output path resolve.
+func slugSegment(segment string) string {
+	segment = norm.NFC.String(segment)
+	ext := path.Ext(segment)
+	if ext == segment {
+		ext = ""
+	}
+
+	source := strings.TrimSuffix(segment, ext)
+	base := sanitizeName(source)
+	ext = sanitizeName(ext)
+
+	// a _ left at an end once the run beside it is dropped goes too, like
+	// the one outputPath makes of the space in "café 🎉"
+	if !strings.HasSuffix(source, "_") {
+		base = strings.TrimRight(base, "_")
+	}
+	if !strings.HasPrefix(source, "_") {
+		base = strings.TrimLeft(base, "_")
+	}
+
+	sum := sha256.Sum256([]byte(segment))
+	hash := hex.EncodeToString(sum[:4])
+	if base == "" {
+		base = hash
+	}
+
+	if max := cfg.MaxPathSegmentLength; max > 0 && len(base)+len(ext) > max {
+		keep := max - len(ext) - len(hash) - 1
+		if keep < 0 {
+			keep = 0
+		}
+		for keep > 0 && !isRuneStart(base[keep]) {
+			keep--
+		}
+		base = base[:keep] + "-" + hash
+	}
+
+	return base + ext
+}
+
+// slugPath applies slugSegment to every segment of a site relative url, so
+// a link written with the source name finds the page at its output path.
+// Spaces become _ first, as outputPath does before sanitizing.
+func slugPath(link string) string {
+	segments := strings.Split(link, "/")
+	for i, segment := range segments {
+		if unescaped, err := url.PathUnescape(segment); err == nil {
+			segment = unescaped
+		}
+		if segment != "" {
+			segments[i] = slugSegment(strings.ReplaceAll(segment, " ", "_"))
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// transliterate strips combining accents from name and then replaces the
+// latin letters that don't decompose, like ø and ß, from transliterations.
+// Capitals are looked up in lower case and keep their first letter capital.
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(name) {
+		lower := unicode.ToLower(r)
+		switch {
+		case unicode.Is(unicode.Mn, r):
+		case transliterations[lower] != "" && lower != r:
+			replacement := transliterations[lower]
+			b.WriteString(strings.ToUpper(replacement[:1]) + replacement[1:])
+		case transliterations[r] != "":
+			b.WriteString(transliterations[r])
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+func sanitizeName(name string) string {
+	if cfg.Transliterate {
+		name = transliterate(name)
+	}
+
+	var b strings.Builder
+	replaced := false
+	keep := func(s string) {
+		if replaced && b.Len() > 0 && !strings.ContainsAny(s[:1], "._-") {
+			b.WriteRune('_')
+		}
+		b.WriteString(s)
+		replaced = false
+	}
+
+	for _, r := range name {
+		switch {
+		case r < unicode.MaxASCII && (r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_.~", r)):
+			keep(string(r))
+		case r > unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)):
+			keep(string(r))
+		default:
+			replaced = true
+		}
+	}
+
+	return b.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
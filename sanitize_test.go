@@ -0,0 +1,104 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSlugSegment(t *testing.T) {
+	cfg = defaultConfig()
+
+	tests := []struct {
+		name    string
+		segment string
+		want    string
+	}{
+		{"safe name", "normal-name_1.html", "normal-name_1.html"},
+		{"question mark", "what?.md", "what.md"},
+		{"colon", "a:b.png", "a_b.png"},
+		{"runs of spaces", "a  b.txt", "a_b.txt"},
+		{"accents and emoji", "café 🎉.md", "cafe.md"},
+		{"space before emoji", "café_🎉.md", "cafe.md"},
+		{"emoji before space", "🎉_party.md", "party.md"},
+		{"underscores of the name", "__init__.py", "__init__.py"},
+		{"only emoji", "🎉.md", "e98c2562.md"},
+		{"non latin letters", "Ωmega.md", "Ωmega.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := slugSegment(tt.segment); got != tt.want {
+				t.Errorf("slugSegment(%q) = %q, want %q", tt.segment, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlugSegmentMaxLength(t *testing.T) {
+	cfg = defaultConfig()
+	cfg.MaxPathSegmentLength = 40
+
+	first := strings.Repeat("long", 20) + "-one.md"
+	second := strings.Repeat("long", 20) + "-two.md"
+
+	a, b := slugSegment(first), slugSegment(second)
+	for _, got := range []string{a, b} {
+		if len(got) > cfg.MaxPathSegmentLength {
+			t.Errorf("%q is %d bytes, longer than %d", got, len(got), cfg.MaxPathSegmentLength)
+		}
+		if !strings.HasSuffix(got, ".md") {
+			t.Errorf("%q lost its extension", got)
+		}
+	}
+	if a == b {
+		t.Errorf("%q and %q were both cut to %q", first, second, a)
+	}
+	if again := slugSegment(first); again != a {
+		t.Errorf("slugSegment(%q) gave %q then %q", first, a, again)
+	}
+}
+
+func TestSanitizedPathsResolveLinks(t *testing.T) {
+	cfg = defaultConfig()
+
+	outPath := outputPath(filepath.Join("content", "notes: draft?", "café 🎉.md"))
+	if want := filepath.Join("public", "notes_draft", "cafe.html"); outPath != want {
+		t.Fatalf("outputPath = %q, want %q", outPath, want)
+	}
+
+	if got := slugPath("/notes:%20draft%3F/caf%C3%A9%20%F0%9F%8E%89.html"); got != pageURL(outPath) {
+		t.Errorf("link resolves to %q, want the page at %q", got, pageURL(outPath))
+	}
+}
+
+func TestSlugSegmentNormalization(t *testing.T) {
+	composed := "Café.md"
+	decomposed := "Café.md"
+
+	tests := []struct {
+		name          string
+		transliterate bool
+		segment       string
+		want          string
+	}{
+		{"composed", true, composed, "Cafe.md"},
+		{"decomposed", true, decomposed, "Cafe.md"},
+		{"composed kept", false, composed, "Café.md"},
+		{"decomposed kept", false, decomposed, "Café.md"},
+		{"letters without a decomposition", true, "Straße Ørsted.md", "Strasse_Orsted.md"},
+		{"capitals without a decomposition", true, "ŁÓDŹ Æther.md", "LODZ_Aether.md"},
+		{"letters without a decomposition kept", false, "Straße Ørsted.md", "Straße_Ørsted.md"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = defaultConfig()
+			cfg.Transliterate = tt.transliterate
+
+			if got := slugSegment(tt.segment); got != tt.want {
+				t.Errorf("slugSegment(%q) = %q, want %q", tt.segment, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+var (
+	reSectionHeading = regexp.MustCompile(`(?is)<h2\b([^>]*)>(.*?)</h2>`)
+	reIDAttr         = regexp.MustCompile(`\sid="([^"]*)"`)
+)
+
+// pageSection is one h2 led section of a page split with the sections front
+// matter field, for building an in page navigation.
+type pageSection struct {
+	ID      string
+	Title   string
+	Content template.HTML
+}
+
+// splitSections wraps each h2 and the content up to the next in a section
+// element and lists them in Sections, for single page sites that navigate
+// between sections. Content before the first h2 is left as is. Headings
+// without an id are given one from their text.
+func (p *page) splitSections() {
+	if p.Type == "" || p.Encrypted || !parseBool(p.Meta["sections"]) {
+		return
+	}
+
+	content := string(p.Content)
+	headings := reSectionHeading.FindAllStringSubmatchIndex(content, -1)
+	if len(headings) == 0 {
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(content[:headings[0][0]])
+
+	p.Sections = make([]pageSection, 0, len(headings))
+	taken := make(map[string]bool)
+	for i, loc := range headings {
+		end := len(content)
+		if i+1 < len(headings) {
+			end = headings[i+1][0]
+		}
+
+		attrs, inner := content[loc[2]:loc[3]], content[loc[4]:loc[5]]
+		title := strings.Join(strings.Fields(plainText(template.HTML(inner))), " ")
+
+		heading := content[loc[0]:loc[1]]
+		id := ""
+		if m := reIDAttr.FindStringSubmatch(attrs); m != nil {
+			id = m[1]
+		} else {
+			id = slug(title)
+			for n := 1; taken[id]; n++ {
+				id = fmt.Sprintf("%s-%d", slug(title), n)
+			}
+			heading = fmt.Sprintf(`<h2 id="%s"%s>%s</h2>`, id, attrs, inner)
+		}
+
+		taken[id] = true
+
+		section := heading + content[loc[1]:end]
+		p.Sections = append(p.Sections, pageSection{
+			ID:      id,
+			Title:   title,
+			Content: template.HTML(section),
+		})
+
+		fmt.Fprintf(&b, `<section class="gen-section" aria-labelledby="%s">%s</section>`, id, section)
+	}
+
+	p.Content = template.HTML(b.String())
+}
+
+// slug lowercases s keeping only letters and digits separated by dashes,
+// normalized and transliterated the same way as output paths.
+func slug(s string) string {
+	s = norm.NFC.String(s)
+	if cfg.Transliterate {
+		s = transliterate(s)
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r > 0x7f)
+	})
+
+	if len(words) == 0 {
+		return "section"
+	}
+
+	return strings.Join(words, "-")
+}
@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestSlug(t *testing.T) {
+	tests := []struct {
+		name          string
+		transliterate bool
+		s             string
+		want          string
+	}{
+		{"words", true, "Getting Started!", "getting-started"},
+		{"composed", true, "Café Menu", "cafe-menu"},
+		{"decomposed", true, "Café Menu", "cafe-menu"},
+		{"composed kept", false, "Café Menu", "café-menu"},
+		{"decomposed kept", false, "Café Menu", "café-menu"},
+		{"nothing left", true, "?!", "section"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg = defaultConfig()
+			cfg.Transliterate = tt.transliterate
+
+			if got := slug(tt.s); got != tt.want {
+				t.Errorf("slug(%q) = %q, want %q", tt.s, got, tt.want)
+			}
+		})
+	}
+}
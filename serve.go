@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"path/filepath"
+	"sync"
+)
+
+// previewMu serialises page previews and -watch rebuilds, a preview swaps
+// the output and -drafts of the build they share while it renders.
+var previewMu sync.Mutex
+
+// serveSite serves the built site on addr, along with /_gen/page for
+// editors to preview a single source.
+func serveSite(addr string) error {
+	root := "public"
+	if *flagPreview {
+		root = previewDirectory
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir(root)))
+	mux.HandleFunc("/_gen/page", servePagePreview)
+
+	log.Printf("[gen/serve] serving %s on %s", root, addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// servePagePreview renders the source named by the path query parameter,
+// like content/docs/intro.md, as html or with format=json as the page data
+// written under public/api. Drafts render too.
+func servePagePreview(w http.ResponseWriter, r *http.Request) {
+	path := filepath.Clean(filepath.FromSlash(r.URL.Query().Get("path")))
+	if _, ok := contentName(path); !ok || filepath.IsAbs(path) {
+		http.Error(w, "path must name a source file under content", http.StatusBadRequest)
+		return
+	}
+
+	previewMu.Lock()
+	p, b, err := renderSinglePage(path, true, nil)
+	previewMu.Unlock()
+	if err != nil {
+		log.Printf("[gen/serve/page] %s", err)
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "json" {
+		content := string(p.Content)
+		if p.Type == "HTML" {
+			content = string(b)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(newAPIPage(p, content))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(b)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// version is set at build time with -ldflags "-X main.version=..."
+var version = "dev"
+
+// site is the site wide context shared by every page as .Site
+type site struct {
+	BuildTime time.Time
+	Version   string
+	GitCommit string
+	Nav       *navNode
+
+	Collections map[string][]*page
+	Menus       map[string][]*navNode
+
+	// Stats are the content statistics -stats prints, for the whole site.
+	Stats contentStats
+
+	// AssetPath is the configured prefix for asset urls.
+	AssetPath string
+
+	// IconTags are the favicon and theme colour tags for the head.
+	IconTags template.HTML
+}
+
+var siteContext = &site{}
+
+// pinnedBuildTime, when set, is the BuildTime of builds instead of now.
+var pinnedBuildTime time.Time
+
+// newSite captures the build metadata once at the start of the run.
+func newSite() *site {
+	buildTime := time.Now()
+	if !pinnedBuildTime.IsZero() {
+		buildTime = pinnedBuildTime
+	}
+
+	return &site{
+		BuildTime: buildTime,
+		Version:   version,
+		GitCommit: gitCommit(),
+		IconTags:  iconTags(cfg),
+		AssetPath: strings.TrimSuffix(cfg.AssetPath, "/"),
+	}
+}
+
+// gitCommit returns the commit being built from the environment or git,
+// best effort and empty when unavailable.
+func gitCommit() string {
+	for _, env := range []string{"GIT_COMMIT", "GITHUB_SHA", "CI_COMMIT_SHA"} {
+		if commit := os.Getenv(env); commit != "" {
+			return commit
+		}
+	}
+
+	out, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const sitemapNamespace = "http://www.sitemaps.org/schemas/sitemap/0.9"
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"urlset"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+type sitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"sitemapindex"`
+	Xmlns    string       `xml:"xmlns,attr"`
+	Sitemaps []sitemapURL `xml:"sitemap"`
+}
+
+// sitemapPages selects the html pages listed in the sitemaps, in url order.
+func sitemapPages(pages map[string]*page) []*page {
+	selected := make([]*page, 0)
+	for _, page := range pages {
+		if page.Type == "" || page.NoIndex || !page.hasHTMLOutput() || matchesAny(cfg.SitemapExclude, pageURL(page.OutPath)) {
+			continue
+		}
+
+		selected = append(selected, page)
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		return pageURL(selected[i].OutPath) < pageURL(selected[j].OutPath)
+	})
+
+	return selected
+}
+
+// renderSitemapXML writes sitemap.xml, or when there are more than
+// SitemapMaxURLs pages splits it across sitemap-1.xml, sitemap-2.xml and so
+// on referenced from sitemap_index.xml. It returns the url crawlers should be
+// pointed at.
+func renderSitemapXML(pages []*page) string {
+	baseURL := strings.TrimSuffix(cfg.BaseURL, "/")
+
+	urls := make([]sitemapURL, 0, len(pages))
+	for _, page := range pages {
+		url := sitemapURL{Loc: baseURL + pageLink(page.OutPath)}
+		if !page.Date.IsZero() {
+			url.LastMod = page.Date.Format(time.RFC3339)
+		}
+		urls = append(urls, url)
+	}
+
+	max := cfg.SitemapMaxURLs
+	if max <= 0 || len(urls) <= max {
+		writeXML(filepath.Join("public", "sitemap.xml"), sitemapURLSet{Xmlns: sitemapNamespace, URLs: urls})
+		return baseURL + "/sitemap.xml"
+	}
+
+	index := sitemapIndex{Xmlns: sitemapNamespace}
+	for i := 0; i*max < len(urls); i++ {
+		end := (i + 1) * max
+		if end > len(urls) {
+			end = len(urls)
+		}
+
+		name := fmt.Sprintf("sitemap-%d.xml", i+1)
+		writeXML(filepath.Join("public", name), sitemapURLSet{Xmlns: sitemapNamespace, URLs: urls[i*max : end]})
+		index.Sitemaps = append(index.Sitemaps, sitemapURL{Loc: baseURL + "/" + name})
+	}
+
+	writeXML(filepath.Join("public", "sitemap_index.xml"), index)
+
+	return baseURL + "/sitemap_index.xml"
+}
+
+func writeXML(outPath string, v interface{}) {
+	out, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("[gen/render/xml] unable to marshal %s: %s", outPath, err)
+		return
+	}
+
+	err = output.WriteFile(outPath, append([]byte(xml.Header), out...))
+	if err != nil {
+		log.Printf("[gen/render/file] unable to create file %s: %s", outPath, err)
+		return
+	}
+
+	log.Printf("[gen/render/file] rendered file %s", outPath)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// contentFS serves the content tree, from the content directory or the
+// archive set with ContentArchive.
+var contentFS fs.FS = os.DirFS("content")
+
+// openedArchive is the content archive opened by openContent, kept open
+// for every build of the process and closed by closeContent.
+var openedArchive struct {
+	path   string
+	fsys   fs.FS
+	closer io.Closer
+}
+
+// openContent returns the file system the content is read from. Archives
+// can hold the tree at their root or in a content directory, zip files are
+// read in place and tar files, optionally gzipped, into memory. An archive
+// is only opened once, rebuilds and previews reuse it.
+func openContent(archive string) (fs.FS, error) {
+	if archive == "" {
+		return os.DirFS("content"), nil
+	}
+
+	if openedArchive.fsys != nil && openedArchive.path == archive {
+		return openedArchive.fsys, nil
+	}
+	closeContent()
+
+	var fsys fs.FS
+	var closer io.Closer
+	var err error
+	switch name := strings.ToLower(archive); {
+	case strings.HasSuffix(name, ".zip"):
+		var r *zip.ReadCloser
+		r, err = zip.OpenReader(archive)
+		if err == nil {
+			fsys, closer = r, r
+		}
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		fsys, err = readTar(archive)
+	default:
+		return nil, fmt.Errorf("[gen/init/content] unable to read %s, content archives must be .zip, .tar, .tar.gz or .tgz", archive)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[gen/init/content] unable to open content archive %s: %s", archive, err)
+	}
+
+	if info, err := fs.Stat(fsys, "content"); err == nil && info.IsDir() {
+		fsys, err = fs.Sub(fsys, "content")
+		if err != nil {
+			if closer != nil {
+				closer.Close()
+			}
+			return nil, err
+		}
+	}
+
+	openedArchive.path, openedArchive.fsys, openedArchive.closer = archive, fsys, closer
+	return fsys, nil
+}
+
+// closeContent closes the content archive, if one is open.
+func closeContent() {
+	if openedArchive.closer != nil {
+		openedArchive.closer.Close()
+	}
+	openedArchive.path, openedArchive.fsys, openedArchive.closer = "", nil, nil
+}
+
+func readTar(archive string) (fs.FS, error) {
+	f, err := os.Open(archive)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(archive), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	fsys := mapFS{}
+	tr := tar.NewReader(r)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return fsys, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := path.Clean(strings.TrimPrefix(header.Name, "./"))
+		if name == "." || !fs.ValidPath(name) {
+			continue
+		}
+
+		file := &mapFile{Mode: fs.FileMode(header.Mode).Perm(), ModTime: header.ModTime}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			file.Mode |= fs.ModeDir
+		case tar.TypeReg:
+			file.Data, err = io.ReadAll(tr)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+
+		fsys[name] = file
+	}
+}
+
+// contentName maps a path under content to its name in contentFS, reporting
+// whether the path is in content at all.
+func contentName(p string) (string, bool) {
+	rel, err := filepath.Rel("content", p)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return filepath.ToSlash(rel), true
+}
+
+// readSource reads a source file, from contentFS when it's under content.
+func readSource(p string) ([]byte, error) {
+	if name, ok := contentName(p); ok {
+		return fs.ReadFile(contentFS, name)
+	}
+
+	return os.ReadFile(p)
+}
+
+// statSource stats a source file, in contentFS when it's under content.
+func statSource(p string) (fs.FileInfo, error) {
+	if name, ok := contentName(p); ok {
+		return fs.Stat(contentFS, name)
+	}
+
+	return os.Stat(p)
+}
+
+// openSource opens a source file, from contentFS when it's under content.
+func openSource(p string) (fs.File, error) {
+	if name, ok := contentName(p); ok {
+		return contentFS.Open(name)
+	}
+
+	return os.Open(p)
+}
+
+// readSourceDir lists a source directory, in contentFS when it's under
+// content.
+func readSourceDir(p string) ([]fs.DirEntry, error) {
+	if name, ok := contentName(p); ok {
+		inodes, err := fs.ReadDir(contentFS, name)
+		if err == nil {
+			return inodes, nil
+		}
+
+		// Paths in contentFS are relative to content, name the directory
+		// as the project knows it instead.
+		var pathErr *fs.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		if cfg.ContentArchive != "" {
+			return nil, fmt.Errorf("unable to read %s in content archive %s: %w", p, cfg.ContentArchive, err)
+		}
+		return nil, fmt.Errorf("unable to read content directory %s: %w", p, err)
+	}
+
+	return os.ReadDir(p)
+}
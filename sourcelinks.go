@@ -0,0 +1,72 @@
+package main
+
+import (
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var reAnchorHref = regexp.MustCompile(`(<a\s(?:[^>]*?\s)?href=")([^"]*)(")`)
+
+// rewriteSourceLinks points links to markdown sources, like ../other.md, and
+// those rendered by Processors at the url the source renders to, so content
+// can link to files without knowing how output paths are built. Relative
+// links resolve from the linking page's directory and absolute ones from
+// content. Links to sources that don't exist are reported and left as is.
+func rewriteSourceLinks(path string, content template.HTML) template.HTML {
+	return template.HTML(reAnchorHref.ReplaceAllStringFunc(string(content), func(a string) string {
+		match := reAnchorHref.FindStringSubmatch(a)
+		ref := match[2]
+		if !isLocalRef(ref) {
+			return a
+		}
+
+		target, suffix := ref, ""
+		if i := strings.IndexAny(ref, "?#"); i >= 0 {
+			target, suffix = ref[:i], ref[i:]
+		}
+
+		if _, processed := processor(target); !isMarkdown(target) && !processed {
+			return a
+		}
+
+		if unescaped, err := url.PathUnescape(target); err == nil {
+			target = unescaped
+		}
+
+		source := filepath.Join(filepath.Dir(path), filepath.FromSlash(target))
+		if strings.HasPrefix(target, "/") {
+			source = filepath.Join("content", filepath.FromSlash(target))
+		}
+
+		if _, err := statSource(source); err != nil {
+			report.Add("validate/links", path, "link to %s doesn't match a source file", ref)
+			return a
+		}
+
+		return match[1] + pageLink(sourceOutPath(source)) + suffix + match[3]
+	}))
+}
+
+// sourceOutPath is the output path a content source renders to, including
+// the move into its language's directory and the file name from its slug.
+func sourceOutPath(source string) string {
+	name := filepath.Base(source)
+	p := page{
+		Path:    source,
+		OutPath: outputPath(source),
+		Name:    strings.TrimSuffix(name, filepath.Ext(name)),
+	}
+	p.localise()
+
+	if s, err := readSource(source); err == nil {
+		meta, _ := parseFrontMatter(s)
+		if outPath, err := slugOutPath(p.OutPath, meta["slug"]); err == nil {
+			p.OutPath = outPath
+		}
+	}
+
+	return filepath.ToSlash(p.OutPath)
+}
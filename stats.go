@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+type sectionStats struct {
+	Section string
+	Pages   int
+	Words   int
+}
+
+type tagStats struct {
+	Tag   string
+	Pages int
+}
+
+type contentStats struct {
+	Pages           int
+	Words           int
+	AverageWords    int
+	ImageReferences int
+	Sections        []sectionStats
+	Tags            []tagStats
+}
+
+// collectStats totals the pages, words and images in the site, by top level
+// section, and counts the pages with each tag from tags front matter.
+// Encrypted pages count as pages without words. It runs once a build, after
+// parsing, for -stats and templates as .Site.Stats.
+func collectStats(pages map[string]*page) contentStats {
+	var stats contentStats
+	sections := make(map[string]*sectionStats)
+	tags := make(map[string]int)
+
+	for _, p := range pages {
+		if p.Type == "" {
+			continue
+		}
+
+		section := "/"
+		if url := strings.TrimPrefix(pageURL(p.OutPath), "/"); strings.Contains(url, "/") {
+			section = "/" + url[:strings.Index(url, "/")] + "/"
+		}
+		if sections[section] == nil {
+			sections[section] = &sectionStats{Section: section}
+		}
+
+		words := 0
+		if !p.Encrypted {
+			words = len(strings.Fields(plainText(p.Content)))
+			for _, match := range reAssetRef.FindAllStringSubmatch(string(p.Content), -1) {
+				if strings.EqualFold(match[1], "img") {
+					stats.ImageReferences++
+				}
+			}
+		}
+
+		for _, tag := range parseList(p.Meta["tags"]) {
+			tags[tag]++
+		}
+
+		stats.Pages++
+		stats.Words += words
+		sections[section].Pages++
+		sections[section].Words += words
+	}
+
+	if stats.Pages > 0 {
+		stats.AverageWords = stats.Words / stats.Pages
+	}
+
+	stats.Sections = make([]sectionStats, 0, len(sections))
+	for _, s := range sections {
+		stats.Sections = append(stats.Sections, *s)
+	}
+	sort.Slice(stats.Sections, func(i, j int) bool {
+		return stats.Sections[i].Section < stats.Sections[j].Section
+	})
+
+	stats.Tags = make([]tagStats, 0, len(tags))
+	for tag, count := range tags {
+		stats.Tags = append(stats.Tags, tagStats{Tag: tag, Pages: count})
+	}
+	sort.Slice(stats.Tags, func(i, j int) bool {
+		return stats.Tags[i].Tag < stats.Tags[j].Tag
+	})
+
+	return stats
+}
+
+// printStats parses the site, without rendering or writing anything, and
+// prints its content statistics as a table, or as json.
+func printStats(w io.Writer, asJSON bool) error {
+	err := parseOnly()
+	if err != nil {
+		return err
+	}
+
+	stats := siteContext.Stats
+
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(stats)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "Pages\t%d\n", stats.Pages)
+	fmt.Fprintf(tw, "Words\t%d\n", stats.Words)
+	fmt.Fprintf(tw, "Average words per page\t%d\n", stats.AverageWords)
+	fmt.Fprintf(tw, "Images referenced\t%d\n", stats.ImageReferences)
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "SECTION\tPAGES\tWORDS")
+	for _, s := range stats.Sections {
+		fmt.Fprintf(tw, "%s\t%d\t%d\n", s.Section, s.Pages, s.Words)
+	}
+
+	if len(stats.Tags) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "TAG\tPAGES")
+		for _, t := range stats.Tags {
+			fmt.Fprintf(tw, "%s\t%d\n", t.Tag, t.Pages)
+		}
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"regexp"
+)
+
+var reFirstParagraph = regexp.MustCompile(`(?s)<p>.*?</p>`)
+
+// setDescription populates the page description from the description front
+// matter, or the plain text of the first paragraph trimmed for meta tags.
+func (p *page) setDescription() {
+	if description, ok := p.Meta["description"]; ok && description != "" {
+		p.Description = description
+		return
+	}
+
+	p.Description = truncateWords(plainText(template.HTML(reFirstParagraph.FindString(string(p.Content)))), descriptionLength)
+}
+
+// setSummary populates the page summary from the summary front matter, the
+// content before the summary delimiter or, failing that, the first paragraph.
+// Only the front matter is used when AutoSummary is disabled.
+func (p *page) setSummary(source []byte) {
+	if summary, ok := p.Meta["summary"]; ok && summary != "" {
+		p.Summary = template.HTML(template.HTMLEscapeString(summary))
+		return
+	}
+
+	if !cfg.AutoSummary {
+		return
+	}
+
+	if cfg.SummaryDelimiter != "" {
+		if before, _, found := bytes.Cut(source, []byte(cfg.SummaryDelimiter)); found {
+			if p.Type == "MD" {
+				p.Summary, _ = cachedMarkdown2html(before, p.markdownOptions)
+			} else {
+				p.Summary = template.HTML(before)
+			}
+			return
+		}
+	}
+
+	p.Summary = template.HTML(reFirstParagraph.FindString(string(p.Content)))
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	reTag          = regexp.MustCompile(`(?s)<[^>]*>`)
+	reScriptStyle  = regexp.MustCompile(`(?is)<(script|style)\b.*?</(script|style)>`)
+	reBlockClosing = regexp.MustCompile(`(?i)</(p|div|h[1-6]|li|pre|blockquote|tr|table|ul|ol)>|<br\s*/?>`)
+	reSpaces       = regexp.MustCompile(`[ \t]+`)
+	reBlankLines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// displayName is the title used for a page without a title in its front
+// matter. The filename casing is kept as is unless TitleCase is enabled, which
+// turns separators into spaces and capitalises each word, so my_post becomes
+// My Post while acronyms like API stay upper case.
+func displayName(name string) string {
+	if !cfg.TitleCase {
+		return name
+	}
+
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return r == '_' || r == '-' || unicode.IsSpace(r)
+	})
+	for i, word := range words {
+		r, size := utf8.DecodeRuneInString(word)
+		words[i] = string(unicode.ToUpper(r)) + word[size:]
+	}
+
+	return strings.Join(words, " ")
+}
+
+const descriptionLength = 160
+
+// truncateWords shortens s to at most n bytes, cutting on a word boundary
+// and marking the cut with an ellipsis.
+func truncateWords(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+
+	cut := strings.LastIndex(s[:n], " ")
+	if cut <= 0 {
+		cut = n
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+	}
+
+	return strings.TrimRight(s[:cut], " ,.;:") + "…"
+}
+
+// plainText strips markup from rendered content, keeping block boundaries as
+// line breaks.
+func plainText(content template.HTML) string {
+	s := reScriptStyle.ReplaceAllString(string(content), "")
+	s = reBlockClosing.ReplaceAllString(s, "$0\n")
+	s = reTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = reSpaces.ReplaceAllString(s, " ")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	s = strings.Join(lines, "\n")
+
+	return strings.TrimSpace(reBlankLines.ReplaceAllString(s, "\n\n"))
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// themePath resolves a project path, such as template/markdown.html, to
+// the same path inside the Theme directory when the project doesn't have the
+// file itself, so project files override theme files.
+func themePath(path string) string {
+	if cfg.Theme == "" {
+		return path
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	themed := filepath.Join(cfg.Theme, path)
+	if _, err := os.Stat(themed); err == nil {
+		return themed
+	}
+
+	return path
+}
+
+// templatePath resolves a file in TemplateDir, falling back to the theme's
+// template directory when the project doesn't have it.
+func templatePath(name string) string {
+	path := filepath.Join(cfg.TemplateDir, name)
+	if cfg.Theme == "" {
+		return path
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	themed := filepath.Join(cfg.Theme, "template", name)
+	if _, err := os.Stat(themed); err == nil {
+		return themed
+	}
+
+	return path
+}
+
+// configuredTemplate resolves a template path from the config, or name in
+// TemplateDir when it isn't set.
+func configuredTemplate(configured, name string) string {
+	if configured == "" {
+		return templatePath(name)
+	}
+
+	return themePath(configured)
+}
+
+// copyThemeStatic copies the theme's static directory into public, skipping
+// any file the project provides at the same path under content.
+func copyThemeStatic() error {
+	if cfg.Theme == "" {
+		return nil
+	}
+
+	root := filepath.Join(cfg.Theme, "static")
+	if _, err := os.Stat(root); err != nil {
+		return nil
+	}
+
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		outPath := filepath.Join("public", rel)
+		if d.IsDir() {
+			return output.MkdirAll(outPath)
+		}
+
+		if _, err := statSource(filepath.Join("content", rel)); err == nil {
+			log.Printf("[gen/theme/static] skipping %s, overridden by content", rel)
+			return nil
+		}
+
+		return copyFile(path, outPath)
+	})
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+var reFirstHeading = regexp.MustCompile(`(?is)<h1\b[^>]*>(.*?)</h1>\s*`)
+
+// setTitleFromHeading uses the text of the first h1 as the title of a
+// markdown page without a title in its front matter, removing the heading
+// from the content under StripTitleHeading for layouts that render the title
+// themselves. Pages without an h1 keep the name derived from the filename.
+func (p *page) setTitleFromHeading() {
+	if !cfg.TitleFromHeading || p.Type != "MD" || p.Meta["title"] != "" {
+		return
+	}
+
+	content := string(p.Content)
+	loc := reFirstHeading.FindStringSubmatchIndex(content)
+	if loc == nil {
+		return
+	}
+
+	title := plainText(template.HTML(content[loc[2]:loc[3]]))
+	if title == "" {
+		return
+	}
+
+	p.Title = strings.Join(strings.Fields(title), " ")
+	if cfg.StripTitleHeading {
+		p.Content = template.HTML(content[:loc[0]] + content[loc[1]:])
+	}
+}
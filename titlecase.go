@@ -0,0 +1,146 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// smallWords stay lower case in titles unless they start or end the title.
+var smallWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "en": true, "for": true, "if": true, "in": true, "nor": true,
+	"of": true, "on": true, "or": true, "per": true, "the": true, "to": true,
+	"up": true, "via": true, "vs": true, "with": true,
+}
+
+var reWord = regexp.MustCompile(`\S+`)
+
+func init() {
+	registerTransform("titleCaseHeadings", func() bool { return cfg.TitleCaseHeadings }, titleCaseHeadings)
+}
+
+// titleCase capitalises s using title case rules, keeping small words like
+// "of" and "the" lower case except at the start, end or after a colon.
+// Words that already have capitals past their first letter, like API or
+// iPhone, and words containing dots, like example.com, are left untouched.
+// Whitespace is kept as is.
+func titleCase(s string) string {
+	return titleCaseSpan(s, true, true)
+}
+
+// titleCaseSpan title cases a piece of a longer title, where atStart and
+// atEnd say whether the piece begins or ends it.
+func titleCaseSpan(s string, atStart, atEnd bool) string {
+	words := reWord.FindAllStringIndex(s, -1)
+
+	var b strings.Builder
+	last := 0
+	for i, loc := range words {
+		b.WriteString(s[last:loc[0]])
+		last = loc[1]
+
+		first := (i == 0 && atStart) || i > 0 && strings.HasSuffix(s[words[i-1][0]:words[i-1][1]], ":")
+		parts := strings.Split(s[loc[0]:loc[1]], "-")
+		for j, part := range parts {
+			if j > 0 {
+				b.WriteString("-")
+			}
+			b.WriteString(titleCaseWord(part, first && j == 0, atEnd && i == len(words)-1 && j == len(parts)-1))
+		}
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+func titleCaseWord(word string, first, last bool) string {
+	start := strings.IndexFunc(word, unicode.IsLetter)
+	if start < 0 || strings.Contains(strings.Trim(word, "."), ".") {
+		return word
+	}
+
+	r, size := utf8.DecodeRuneInString(word[start:])
+	rest := word[start+size:]
+	if strings.IndexFunc(rest, unicode.IsUpper) >= 0 {
+		return word
+	}
+
+	bare := strings.ToLower(strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }))
+	if smallWords[bare] && !first && !last {
+		return strings.ToLower(word)
+	}
+
+	return word[:start] + string(unicode.ToUpper(r)) + rest
+}
+
+// titleCased reports whether the page title and headings should be title
+// cased, which the titlecase front matter field overrides for the page.
+func (p *page) titleCased() bool {
+	if v, ok := p.Meta["titlecase"]; ok {
+		return parseBool(v)
+	}
+
+	return cfg.TitleCaseTitles
+}
+
+// titleCaseHeadings title cases the text of every heading, skipping inline
+// code, unless the page opts out in its front matter.
+func titleCaseHeadings(doc *html.Node, p *page) error {
+	if v, ok := p.Meta["titlecase"]; ok && !parseBool(v) {
+		return nil
+	}
+
+	walkElements(doc, func(n *html.Node) {
+		switch n.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			titleCaseText(n)
+		}
+	})
+
+	return nil
+}
+
+// titleCaseText title cases the text nodes of a heading as one title, so a
+// word next to inline markup isn't mistaken for the start or end of it.
+func titleCaseText(heading *html.Node) {
+	type segment struct {
+		node *html.Node
+		code bool
+	}
+
+	var segments []segment
+	var collect func(n *html.Node, code bool)
+	collect = func(n *html.Node, code bool) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.TextNode:
+				segments = append(segments, segment{node: c, code: code})
+			case html.ElementNode:
+				collect(c, code || c.DataAtom == atom.Code)
+			}
+		}
+	}
+	collect(heading, false)
+
+	blank := func(s segment) bool { return strings.TrimSpace(s.node.Data) == "" }
+	for i, s := range segments {
+		if s.code || blank(s) {
+			continue
+		}
+
+		atStart, atEnd := true, true
+		for _, before := range segments[:i] {
+			atStart = atStart && blank(before)
+		}
+		for _, after := range segments[i+1:] {
+			atEnd = atEnd && blank(after)
+		}
+
+		s.node.Data = titleCaseSpan(s.node.Data, atStart, atEnd)
+	}
+}
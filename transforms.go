@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HTMLTransform rewrites a rendered page in place before it's written.
+type HTMLTransform func(doc *html.Node, p *page) error
+
+type registeredTransform struct {
+	Name      string
+	Enabled   func() bool
+	Transform HTMLTransform
+}
+
+var htmlTransforms []registeredTransform
+
+// registerTransform adds a transform to the end of the pipeline, enabled
+// reports whether it runs unless the Transforms config overrides it by name.
+func registerTransform(name string, enabled func() bool, transform HTMLTransform) {
+	htmlTransforms = append(htmlTransforms, registeredTransform{
+		Name:      name,
+		Enabled:   enabled,
+		Transform: transform,
+	})
+}
+
+func enabledTransforms() []registeredTransform {
+	enabled := make([]registeredTransform, 0, len(htmlTransforms))
+	for _, t := range htmlTransforms {
+		on := t.Enabled != nil && t.Enabled()
+		if override, ok := cfg.Transforms[t.Name]; ok {
+			on = override
+		}
+
+		if on {
+			enabled = append(enabled, t)
+		}
+	}
+
+	return enabled
+}
+
+// writeTransformed runs the rendered page through every enabled transform,
+// sharing a single parse and serialise, and writes the result. Without any
+// enabled transforms the rendered bytes are written untouched.
+func writeTransformed(w io.Writer, rendered []byte, p *page) error {
+	transforms := enabledTransforms()
+	if len(transforms) == 0 {
+		_, err := w.Write(rendered)
+		return err
+	}
+
+	doc, err := html.Parse(bytes.NewReader(rendered))
+	if err != nil {
+		return fmt.Errorf("unable to parse rendered html: %s", err)
+	}
+
+	for _, t := range transforms {
+		err = t.Transform(doc, p)
+		if err != nil {
+			return fmt.Errorf("%s transform failed: %s", t.Name, err)
+		}
+	}
+
+	return html.Render(w, doc)
+}
+
+// walkElements calls fn for every element below n in document order.
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		walkElements(c, fn)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walkElements(n, func(e *html.Node) {
+		if found == nil && e.DataAtom == a {
+			found = e
+		}
+	})
+
+	return found
+}
+
+func getAttr(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(getAttr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appendToHead parses a snippet of markup into the end of the document head.
+func appendToHead(doc *html.Node, snippet string) error {
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		return fmt.Errorf("document has no head")
+	}
+
+	nodes, err := html.ParseFragment(strings.NewReader(snippet), head)
+	if err != nil {
+		return err
+	}
+
+	for _, n := range nodes {
+		head.AppendChild(n)
+	}
+
+	return nil
+}
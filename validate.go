@@ -0,0 +1,96 @@
+package main
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// requiredFields returns the front matter fields required for a source path,
+// the longest matching SectionRequiredFields prefix overrides RequiredFields.
+func requiredFields(sourcePath string) []string {
+	fields := cfg.RequiredFields
+
+	longest := -1
+	for section, sectionFields := range cfg.SectionRequiredFields {
+		section = strings.Trim(section, "/")
+		if (sourcePath == section || strings.HasPrefix(sourcePath, section+"/")) && len(section) > longest {
+			fields = sectionFields
+			longest = len(section)
+		}
+	}
+
+	return fields
+}
+
+// validateRequiredFields reports any required front matter fields missing
+// from the page.
+func (p *page) validateRequiredFields() {
+	for _, field := range requiredFields(p.SourcePath) {
+		if value, ok := p.Meta[strings.ToLower(field)]; !ok || value == "" {
+			report.Add("validate/frontmatter", p.Path, "missing required field %s", field)
+		}
+	}
+}
+
+// validateSite builds the site in memory to run every check without writing
+// anything, and returns the exit code, failing on any problem found. Caches
+// stay untouched and markdown warnings count as problems.
+func validateSite() int {
+	cacheWrites = false
+	cfg.MarkdownCache = false
+	cfg.LQIP = false
+	*flagStrictMarkdown = true
+
+	err := build(newMemoryOutput())
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+
+	lintInternalLinks(pages)
+
+	report.Print()
+	if len(report.Problems) > 0 {
+		log.Printf("[gen/validate] found %d problems", len(report.Problems))
+		return 1
+	}
+
+	return 0
+}
+
+// lintInternalLinks checks every link between the rendered pages resolves
+// to a file in the output, taking links the way canonicalURL does, so a link
+// to a directory needs its index.html.
+func lintInternalLinks(pages map[string]*page) {
+	for _, page := range pages {
+		if page.Type == "" || !page.hasHTMLOutput() {
+			continue
+		}
+
+		rendered, err := output.ReadFile(page.OutPath)
+		if err != nil {
+			continue
+		}
+
+		url := pageURL(page.OutPath)
+		seen := make(map[string]bool)
+		for _, match := range reAnchorHref.FindAllStringSubmatch(string(rendered), -1) {
+			ref := match[2]
+			if !isLocalRef(ref) || seen[ref] {
+				continue
+			}
+
+			seen[ref] = true
+
+			link := canonicalURL(resolveRef(url, ref))
+			if strings.HasSuffix(link, "/") {
+				link += "index.html"
+			}
+
+			if !output.Exists(filepath.Join("public", filepath.FromSlash(link))) {
+				report.Add("validate/links", page.Path, "broken link to %s", ref)
+			}
+		}
+	}
+}
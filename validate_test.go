@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestValidateSiteLeavesCaches(t *testing.T) {
+	newTestSite(t, map[string]string{
+		"content/index.md":      "# Home\n\n[Docs](/docs), [index](/docs/index.html) and [start](docs/start.html)\n",
+		"content/docs/index.md": "# Docs\n",
+		"content/docs/start.md": "# Start\n",
+	})
+	cfg.OGImages = true
+	cfg.URLStyle = "pretty"
+	strict := *flagStrictMarkdown
+	t.Cleanup(func() {
+		cacheWrites = true
+		*flagStrictMarkdown = strict
+	})
+
+	if code := validateSite(); code != 0 {
+		t.Errorf("validateSite = %d, want no problems, reported %v", code, report.Problems)
+	}
+
+	if _, err := os.Stat(".cache"); !os.IsNotExist(err) {
+		t.Errorf("validateSite wrote to .cache")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// verifyOutputs re-reads the html written for every page, and the sitemap,
+// archive and recent pages, reporting any that are missing, empty or don't
+// parse into an html document, which usually points at a template bug.
+func verifyOutputs(pages map[string]*page) {
+	paths := map[string]string{
+		filepath.Join("public", "sitemap.html"): filepath.Join("content", "sitemap.html"),
+	}
+	if archiveTemplate != nil {
+		paths[filepath.Join("public", "archive", "index.html")] = filepath.Join("content", "archive", "index.html")
+	}
+	if recentTemplate != nil && cfg.Recent > 0 {
+		paths[cfg.RecentPath] = filepath.Join("content", "recent", "index.html")
+	}
+
+	for _, page := range pages {
+		if page.Type == "" || !page.hasHTMLOutput() {
+			continue
+		}
+
+		paths[page.OutPath] = page.Path
+		if page.AMPURL != "" {
+			paths[ampOutPath(page.OutPath)] = page.Path
+		}
+	}
+
+	for outPath, source := range paths {
+		b, err := output.ReadFile(outPath)
+		if err != nil {
+			report.Add("verify/output", source, "unable to read %s: %s", outPath, err)
+			continue
+		}
+
+		if len(bytes.TrimSpace(b)) == 0 {
+			report.Add("verify/output", source, "%s is empty", outPath)
+			continue
+		}
+
+		doc, err := html.Parse(bytes.NewReader(b))
+		if err != nil {
+			report.Add("verify/output", source, "%s doesn't parse as html: %s", outPath, err)
+			continue
+		}
+
+		if !hasElements(doc) {
+			report.Add("verify/output", source, "%s doesn't contain any html elements", outPath)
+		}
+	}
+}
+
+// hasElements reports whether the document has any markup of its own, the
+// parser adds html, head and body to whatever it's given.
+func hasElements(doc *html.Node) bool {
+	found := false
+	walkElements(doc, func(n *html.Node) {
+		switch strings.ToLower(n.Data) {
+		case "html", "head", "body":
+			if len(n.Attr) > 0 {
+				found = true
+			}
+		default:
+			found = true
+		}
+	})
+
+	return found
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const watchInterval = 500 * time.Millisecond
+
+// templateDependents maps each template and partial to the pages rendered
+// with it in the last full build, and each include to the markdown pages
+// expanding it, so -watch only rebuilds the pages a change affects.
+var templateDependents map[string][]*page
+
+// templates returns the templates and partials the page is rendered with,
+// by their path from templatePath.
+func (p *page) templates() []string {
+	used := []string{templatePath("navigation.html"), templatePath("static.html")}
+	if p.Layout != "" {
+		used = append(used, templatePath("static-"+p.Layout+".html"))
+	}
+
+	if p.hasFooter() {
+		used = append(used, templatePath("footer.html"))
+	}
+
+	// Layouts without a template of their own fall back to markdown.html,
+	// and depend on the layout's path too so adding one re-renders them.
+	if p.Type == "MD" {
+		layout := ""
+		if p.Layout != "" {
+			layout = templatePath("layout-" + p.Layout + ".html")
+			used = append(used, layout)
+		}
+		if _, err := os.Stat(layout); layout == "" || err != nil {
+			used = append(used, templatePath("markdown.html"))
+		}
+	}
+
+	for _, format := range p.Outputs {
+		if format != "html" {
+			used = append(used, templatePath("output."+format))
+		}
+	}
+
+	if p.Encrypted {
+		used = append(used, configuredTemplate(cfg.EncryptedTemplate, "encrypted.html"))
+	}
+
+	if p.AMPURL != "" {
+		used = append(used, configuredTemplate(cfg.AMPTemplate, "amp.html"))
+	}
+
+	return used
+}
+
+// mapTemplateDependents builds templateDependents from the rendered pages.
+// Includes that templates call are left out, since which pages those
+// templates render isn't known, so changing one rebuilds the site.
+func mapTemplateDependents(pages map[string]*page) map[string][]*page {
+	dependents := make(map[string][]*page)
+	bySource := make(map[string]*page, len(pages))
+	for _, page := range pages {
+		if page.Type == "" {
+			continue
+		}
+		bySource[page.Path] = page
+
+		for _, path := range page.templates() {
+			path = filepath.Clean(path)
+			dependents[path] = append(dependents[path], page)
+		}
+	}
+
+	includeUsersMu.Lock()
+	defer includeUsersMu.Unlock()
+
+	for include, sources := range includeUsers {
+		if templateIncludes[include] {
+			continue
+		}
+
+		for source := range sources {
+			if page, ok := bySource[source]; ok {
+				dependents[include] = append(dependents[include], page)
+			}
+		}
+	}
+
+	return dependents
+}
+
+// watchTemplates polls the template and includes directories after a build
+// and, when a template or include changes, rebuilds only the pages that use
+// it into out. Changes to files no page depends on, like sitemap.html,
+// rebuild the site.
+func watchTemplates(out outputFS) {
+	log.Printf("[gen/watch] watching templates for changes")
+
+	seen := templateModTimes()
+	for range time.Tick(watchInterval) {
+		current := templateModTimes()
+
+		changed := make([]string, 0)
+		for path, modTime := range current {
+			if previous, ok := seen[path]; !ok || !previous.Equal(modTime) {
+				changed = append(changed, path)
+			}
+		}
+		for path := range seen {
+			if _, ok := current[path]; !ok {
+				changed = append(changed, path)
+			}
+		}
+		seen = current
+
+		if len(changed) == 0 {
+			continue
+		}
+
+		sort.Strings(changed)
+		rebuildTemplates(out, changed)
+	}
+}
+
+// templateModTimes returns the modification time of every file in the
+// template and includes directories and every template a page depends on.
+func templateModTimes() map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+
+	dirs := []string{cfg.TemplateDir, includesDirectory}
+	if cfg.Theme != "" {
+		dirs = append(dirs, filepath.Join(cfg.Theme, "template"), filepath.Join(cfg.Theme, includesDirectory))
+	}
+
+	for _, dir := range dirs {
+		filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return nil
+			}
+
+			if info, err := d.Info(); err == nil {
+				modTimes[filepath.Clean(path)] = info.ModTime()
+			}
+			return nil
+		})
+	}
+
+	for path := range templateDependents {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+
+	return modTimes
+}
+
+// rebuildTemplates re-renders the pages depending on the changed templates,
+// reparsing those depending on a changed include first, or rebuilds the
+// whole site when one of them has no dependents.
+func rebuildTemplates(out outputFS, changed []string) {
+	previewMu.Lock()
+	defer previewMu.Unlock()
+
+	affected := make(map[string]*page)
+	reparse := make(map[string]bool)
+	for _, path := range changed {
+		dependents, ok := templateDependents[path]
+		if !ok {
+			log.Printf("[gen/watch] %s changed and no page depends on it, rebuilding the site", path)
+
+			start := time.Now()
+			resetSite()
+			err := build(out)
+			if err != nil {
+				log.Printf("[gen/watch] unable to rebuild the site: %s", err)
+				return
+			}
+			logDuration("watch", "rebuilt site", start)
+			report.Print()
+			return
+		}
+
+		log.Printf("[gen/watch] %s changed, rebuilding the %d pages that use it", path, len(dependents))
+		includeUsersMu.Lock()
+		_, include := includeUsers[path]
+		includeUsersMu.Unlock()
+		for _, page := range dependents {
+			affected[page.OutPath] = page
+			if include {
+				reparse[page.OutPath] = true
+			}
+		}
+	}
+
+	// Pages keep the site and assets they were parsed with, only the
+	// templates are reloaded.
+	err := loadTemplates()
+	if err != nil {
+		log.Printf("[gen/watch] unable to reload templates: %s", err)
+		return
+	}
+
+	start := time.Now()
+	forEachPage(affected, func(p *page) {
+		if reparse[p.OutPath] {
+			p.reparseContent()
+		}
+		p.reloadPartials()
+		p.Render()
+	})
+	logDuration("watch", fmt.Sprintf("rebuilt %d pages", len(affected)), start)
+}
+
+// resetSite clears what the last build left behind that prepareBuild
+// doesn't, the pages, the includes they use and the problems reported, so a
+// rebuild starts from the content as it is now rather than adding to the
+// previous one.
+func resetSite() {
+	pagesMu.Lock()
+	pages = make(map[string]*page)
+	pagesMu.Unlock()
+
+	includeUsersMu.Lock()
+	includeUsers = make(map[string]map[string]bool)
+	templateIncludes = make(map[string]bool)
+	includeUsersMu.Unlock()
+
+	report.Reset()
+	markdownWarnings.Store(0)
+	templateDependents = nil
+}
+
+// reparseContent parses the page's source again for what's derived from
+// its content, which changes with the includes it expands. What the last
+// full build linked into the page, like its children and backlinks, stays.
+func (p *page) reparseContent() {
+	parsed := parseFile(p.Path, outputPath(p.Path), p.Name)
+	if parsed == nil {
+		log.Printf("[gen/watch] unable to reparse %s, keeping its content", p.Path)
+		return
+	}
+
+	p.Content = parsed.Content
+	p.Sections = parsed.Sections
+	p.Summary = parsed.Summary
+	p.Lead = parsed.Lead
+	p.Description = parsed.Description
+	p.extraImports = parsed.extraImports
+}
+
+// reloadPartials rereads the navigation and static imports partials NewPage
+// and parseFile copy into the page.
+func (p *page) reloadPartials() {
+	navigation, err := os.ReadFile(templatePath("navigation.html"))
+	if err != nil {
+		log.Printf("[gen/watch] unable to open navigation partial: %s", err)
+	} else {
+		p.Navigation = template.HTML(navigation)
+	}
+
+	base, err := layoutStaticImports(p.Layout)
+	if err != nil {
+		log.Printf("[gen/watch] unable to open static imports partial for %s: %s", p.Path, err)
+	} else {
+		p.StaticImports = base + p.extraImports
+	}
+}
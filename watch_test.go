@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRebuildPagesUsingInclude(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"includes/notice.md": "Old notice.\n",
+		"content/uses.md":    "# Uses\n\n{{include \"notice\"}}\n",
+		"content/other.md":   "# Other\n",
+	})
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	notice := filepath.Join("includes", "notice.md")
+	dependents := templateDependents[notice]
+	if len(dependents) != 1 || dependents[0].Path != filepath.Join("content", "uses.md") {
+		t.Fatalf("includes/notice.md has dependents %v, want content/uses.md", dependents)
+	}
+
+	err = os.WriteFile(notice, []byte("New notice.\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuildTemplates(out, []string{notice})
+
+	b, err := out.ReadFile("public/uses.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "New notice.") {
+		t.Errorf("public/uses.html wasn't rebuilt with the changed include:\n%s", b)
+	}
+}
+
+func TestIncludeCalledByTemplateHasNoDependents(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"includes/banner.html":   "<p>Banner</p>",
+		"content/page.md":        "# Page\n\n{{include \"banner\"}}\n",
+		"template/markdown.html": `<html><body>{{include "banner"}}{{.Content}}</body></html>`,
+	})
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	if dependents, ok := templateDependents[filepath.Join("includes", "banner.html")]; ok {
+		t.Errorf("includes/banner.html has dependents %v, want a site rebuild", dependents)
+	}
+}
+
+func TestRebuildTemplateKeepsMovedAssets(t *testing.T) {
+	out := newTestSite(t, map[string]string{
+		"content/docs/page.md":  "# Page\n\n![Logo](/img/logo.png)\n",
+		"content/img/logo.png":  "logo",
+		"content/img/other.png": "other",
+	})
+	cfg.AssetLayout = "flatten"
+
+	err := build(out)
+	if err != nil {
+		t.Fatalf("build: %s", err)
+	}
+
+	markdown := filepath.Join("template", "markdown.html")
+	err = os.WriteFile(markdown, []byte(`<html><body><main>{{.Content}}</main></body></html>`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebuildTemplates(out, []string{markdown})
+
+	b, err := out.ReadFile("public/docs/page.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), "<main>") {
+		t.Fatalf("public/docs/page.html wasn't rebuilt with the changed template:\n%s", b)
+	}
+	if !strings.Contains(string(b), `src="/assets/logo.png"`) {
+		t.Errorf("public/docs/page.html lost the moved asset:\n%s", b)
+	}
+}